@@ -0,0 +1,434 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package seared
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// operands returns the expressions e is composed of, including the
+// synchronization expression of rules
+func operands(e Expression) []Expression {
+	switch e := e.(type) {
+	case *rule:
+		if e.sync != nil {
+			return []Expression{e.expression, e.sync}
+		}
+		return []Expression{e.expression}
+	case *expression:
+		return e.operands
+	}
+	return nil
+}
+
+// reachableRules returns the rules reachable from the roots expressions in
+// depth first order.
+func reachableRules(roots ...Expression) []*rule {
+	rules := []*rule{}
+	seen := map[Expression]bool{}
+	var visit func(e Expression)
+	visit = func(e Expression) {
+		if e == nil || seen[e] {
+			return
+		}
+		seen[e] = true
+		if r, ok := e.(*rule); ok {
+			rules = append(rules, r)
+		}
+		for _, o := range operands(e) {
+			visit(o)
+		}
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+	return rules
+}
+
+// nullable reports whether e may succeed without consuming input given the
+// nullable rules.
+func nullable(e Expression, rules map[*rule]bool) bool {
+	switch e := e.(type) {
+	case *rule:
+		return rules[e]
+	case *expression:
+		switch e.name {
+		case "Empty", "End", "Optional", "ZeroOrMore", "Test", "TestNot", "Cut":
+			return true
+		case "OneOrMore", "Action", "Token":
+			return nullable(e.operands[0], rules)
+		case "Literal":
+			return e.argument.(string) == ""
+		case "Throw":
+			if e.parser != nil {
+				if recovery, ok := e.parser.recoveries[e.argument.(throwArgument).label]; ok {
+					return nullable(recovery, rules)
+				}
+			}
+		case "Sequence":
+			for _, o := range e.operands {
+				if !nullable(o, rules) {
+					return false
+				}
+			}
+			return true
+		case "Choice":
+			for _, o := range e.operands {
+				if nullable(o, rules) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// nullableRules returns the set of rules which may succeed without consuming
+// input.
+func nullableRules(rules []*rule) map[*rule]bool {
+	nullables := map[*rule]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range rules {
+			if !nullables[r] && nullable(r.expression, nullables) {
+				nullables[r] = true
+				changed = true
+			}
+		}
+	}
+	return nullables
+}
+
+// leftOperands returns the operands of e which may be applied at the same
+// input position as e.
+func leftOperands(e Expression, nullables map[*rule]bool) []Expression {
+	switch e := e.(type) {
+	case *rule:
+		return []Expression{e.expression}
+	case *expression:
+		if e.name == "Sequence" {
+			for i, o := range e.operands {
+				if !nullable(o, nullables) {
+					return e.operands[:i+1]
+				}
+			}
+		}
+	}
+	return operands(e)
+}
+
+// leftRecursive reports whether r may be applied again at the same input
+// position while being applied.
+func leftRecursive(r *rule, nullables map[*rule]bool) bool {
+	seen := map[Expression]bool{}
+	var visit func(e Expression) bool
+	visit = func(e Expression) bool {
+		for _, o := range leftOperands(e, nullables) {
+			if o == r {
+				return true
+			}
+			if o != nil && !seen[o] {
+				seen[o] = true
+				if visit(o) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return visit(r)
+}
+
+// visitPaths calls f with every expression reachable from root and the names
+// of the rules leading to it from the parser named name, which is omitted when
+// root is the rule named so.
+func visitPaths(name string, root Expression, f func(e Expression, path []string)) {
+	seen := map[Expression]bool{}
+	path := []string{}
+	if r, ok := root.(*rule); !ok || r.Name() != name {
+		path = append(path, name)
+	}
+	var visit func(e Expression)
+	visit = func(e Expression) {
+		if e == nil || seen[e] {
+			return
+		}
+		seen[e] = true
+		if r, ok := e.(*rule); ok {
+			path = append(path, r.Name())
+			defer func() { path = path[:len(path)-1] }()
+		}
+		f(e, path)
+		for _, o := range operands(e) {
+			visit(o)
+		}
+	}
+	visit(root)
+}
+
+// roots returns the main and recovery expressions of p, sorted by label, with
+// the names of the paths to the expressions reachable from them.
+func (p *Parser) roots() ([]Expression, []string) {
+	roots, names := []Expression{p.main}, []string{p.name}
+	labels := []string{}
+	for label := range p.recoveries {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		roots = append(roots, p.recoveries[label])
+		names = append(names, "recovery "+label)
+	}
+	return roots, names
+}
+
+// nullableRepetitions returns an error for every repetition reachable from the
+// main or recovery expressions of p whose body may succeed without consuming
+// input, which would make the repetition loop forever, naming the path of
+// rules to it.
+func (p *Parser) nullableRepetitions() GrammarErrors {
+	roots, names := p.roots()
+	nullables := nullableRules(reachableRules(roots...))
+	var errs GrammarErrors
+	for i, root := range roots {
+		visitPaths(names[i], root, func(e Expression, path []string) {
+			if x, ok := e.(*expression); ok && (x.name == "ZeroOrMore" || x.name == "OneOrMore") && nullable(x.operands[0], nullables) {
+				errs = append(errs, &GrammarError{
+					Defect:     NullableRepetition,
+					Path:       append([]string{}, path...),
+					Expression: x.Expectation(),
+					Problem:    "repeated expression may match empty input",
+				})
+			}
+		})
+	}
+	return errs
+}
+
+// leftCycle returns the names of the rules of a cycle of applications of r at
+// the same input position, starting and ending with r, if any.
+func leftCycle(r *rule, nullables map[*rule]bool) []string {
+	seen := map[Expression]bool{}
+	var visit func(e Expression, path []string) []string
+	visit = func(e Expression, path []string) []string {
+		for _, o := range leftOperands(e, nullables) {
+			if o == r {
+				return append(path, r.Name())
+			}
+			if o == nil || seen[o] {
+				continue
+			}
+			seen[o] = true
+			p := path
+			if or, ok := o.(*rule); ok {
+				p = append(path[:len(path):len(path)], or.Name())
+			}
+			if cycle := visit(o, p); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return visit(r, []string{r.Name()})
+}
+
+// literal returns the only text e matches, if any.
+func literal(e Expression, seen map[Expression]bool) (string, bool) {
+	if seen[e] {
+		return "", false
+	}
+	seen[e] = true
+	defer delete(seen, e)
+	switch e := e.(type) {
+	case *rule:
+		return literal(e.expression, seen)
+	case *expression:
+		switch e.name {
+		case "Empty":
+			return "", true
+		case "Rune":
+			return string(e.argument.(rune)), true
+		case "Literal":
+			return e.argument.(string), true
+		case "Action", "Token":
+			return literal(e.operands[0], seen)
+		case "Sequence":
+			s := ""
+			for _, o := range e.operands {
+				l, ok := literal(o, seen)
+				if !ok {
+					return "", false
+				}
+				s += l
+			}
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// single returns whether e matches exactly one character and a function
+// telling which ones.
+func single(e Expression) (func(rune) bool, bool) {
+	if r, ok := e.(*rule); ok {
+		e = r.expression
+	}
+	x, ok := e.(*expression)
+	if !ok {
+		return nil, false
+	}
+	switch x.name {
+	case "Any":
+		return func(rune) bool { return true }, true
+	case "Rune":
+		return func(c rune) bool { return c == x.argument.(rune) }, true
+	case "Range":
+		bounds := x.argument.([2]rune)
+		return func(c rune) bool { return c >= bounds[0] && c <= bounds[1] }, true
+	case "AnyOf":
+		return func(c rune) bool { return strings.ContainsRune(x.argument.(string), c) }, true
+	}
+	return nil, false
+}
+
+// succeeds reports whether e succeeds whatever the input is.
+func succeeds(e Expression, seen map[Expression]bool) bool {
+	if e == nil || seen[e] {
+		return false
+	}
+	seen[e] = true
+	defer delete(seen, e)
+	switch e := e.(type) {
+	case *rule:
+		return succeeds(e.expression, seen)
+	case *expression:
+		switch e.name {
+		case "Empty", "Optional", "ZeroOrMore", "Cut":
+			return true
+		case "Action", "Token":
+			return succeeds(e.operands[0], seen)
+		case "Sequence":
+			for _, o := range e.operands {
+				if !succeeds(o, seen) {
+					return false
+				}
+			}
+			return true
+		case "Choice":
+			for _, o := range e.operands {
+				if succeeds(o, seen) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// shadows reports whether the choice alternative a always matches where the
+// later alternative b would, so b is never tried.
+func shadows(a, b Expression, nullables map[*rule]bool) bool {
+	if succeeds(a, map[Expression]bool{}) {
+		return true
+	}
+	lb, isLiteral := literal(b, map[Expression]bool{})
+	if la, ok := literal(a, map[Expression]bool{}); ok && isLiteral {
+		return strings.HasPrefix(lb, la)
+	}
+	if matches, ok := single(a); ok {
+		if isLiteral && lb != "" {
+			c, _ := utf8.DecodeRuneInString(lb)
+			return matches(c)
+		}
+		if x, ok := a.(*expression); ok && x.name == "Any" {
+			return !nullable(b, nullables)
+		}
+	}
+	return false
+}
+
+// Analyze returns the possible defects of the grammar of p: cycles of left
+// recursive rules, which can not be generated and make parsing slower, rules
+// built but not reachable from the main or recovery expressions, and choice
+// alternatives never tried because an earlier one always matches instead. The
+// result is empty for grammars without defects, so tests may assert that.
+func (p *Parser) Analyze() GrammarErrors {
+	var errs GrammarErrors
+	roots, names := p.roots()
+	rules := reachableRules(roots...)
+	nullables := nullableRules(rules)
+	cycles := map[string]bool{}
+	for _, r := range rules {
+		cycle := leftCycle(r, nullables)
+		if cycle == nil {
+			continue
+		}
+		members := append([]string{}, cycle[1:]...)
+		sort.Strings(members)
+		if key := strings.Join(members, " "); !cycles[key] {
+			cycles[key] = true
+			errs = append(errs, &GrammarError{Defect: LeftRecursion, Path: cycle, Problem: "left recursive rules"})
+		}
+	}
+	reachable := map[Expression]bool{}
+	for _, r := range rules {
+		reachable[r] = true
+	}
+	unreachable := []string{}
+	for _, r := range p.builder.rules {
+		if !reachable[r] {
+			unreachable = append(unreachable, r.(*rule).Name())
+		}
+	}
+	sort.Strings(unreachable)
+	for _, name := range unreachable {
+		errs = append(errs, &GrammarError{Defect: UnreachableRule, Path: []string{name}, Problem: "unreachable rule"})
+	}
+	for i, root := range roots {
+		visitPaths(names[i], root, func(e Expression, path []string) {
+			x, ok := e.(*expression)
+			if !ok || x.name != "Choice" {
+				return
+			}
+			for j, b := range x.operands {
+				for _, a := range x.operands[:j] {
+					if shadows(a, b, nullables) {
+						errs = append(errs, &GrammarError{
+							Defect:     ShadowedAlternative,
+							Path:       append([]string{}, path...),
+							Expression: x.Expectation(),
+							Problem:    "alternative " + b.Expectation() + " is shadowed by " + a.Expectation(),
+						})
+						break
+					}
+				}
+			}
+		})
+	}
+	return errs
+}
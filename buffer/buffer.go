@@ -32,8 +32,15 @@ import (
 	"github.com/lalloni/seared/location"
 )
 
+// Buffer is the input of a parse. Positions in a buffer are offsets in the
+// units of its implementation, so they must be advanced with Decode.
 type Buffer interface {
 	Length() int
+	// Has tells whether there is input at pos
+	Has(pos int) bool
+	// Decode returns the rune at pos and the position following it, or pos
+	// itself at the end of input
+	Decode(pos int) (r rune, next int)
 	Input() string
 	Rune(pos int) rune
 	Runes(start, end int) []rune
@@ -49,9 +56,9 @@ type bufferReader struct {
 }
 
 func (r *bufferReader) ReadRune() (rune, int, error) {
-	if r.pos < r.buf.Length() {
-		ru := r.buf.Rune(r.pos)
-		r.pos++
+	if r.buf.Has(r.pos) {
+		ru, next := r.buf.Decode(r.pos)
+		r.pos = next
 		return ru, len(string(ru)), nil
 	}
 	return 0, 0, io.EOF
@@ -60,6 +67,8 @@ func (r *bufferReader) ReadRune() (rune, int, error) {
 type buffer struct {
 	input []rune
 	nls   []int
+	// nlo are the byte offsets of the newlines
+	nlo []int
 }
 
 func StringBuffer(input string) Buffer {
@@ -74,6 +83,13 @@ func BytesBuffer(input []byte) Buffer {
 	return &buffer{input: []rune(string(input))}
 }
 
+func (b *buffer) Decode(pos int) (rune, int) {
+	if b.Has(pos) {
+		return b.input[pos], pos + 1
+	}
+	return 0, pos
+}
+
 func (b *buffer) Rune(pos int) rune {
 	if pos < b.Length() {
 		return b.input[pos]
@@ -100,6 +116,10 @@ func (b *buffer) Input() string {
 	return string(b.input)
 }
 
+func (b *buffer) Has(pos int) bool {
+	return pos >= 0 && pos < len(b.input)
+}
+
 func (b *buffer) Length() int {
 	return len(b.input)
 }
@@ -121,26 +141,33 @@ func (b *buffer) Line(n int) string {
 func (b *buffer) Location(pos int) location.Location {
 	nls := b.newlines()
 	l := sort.SearchInts(nls, pos)
-	d := -1
+	d, o := -1, 0
 	if l > 0 {
-		d = nls[l-1]
+		d, o = nls[l-1], b.nlo[l-1]+1
+	}
+	for _, r := range b.Runes(d+1, pos) {
+		o += len(string(r))
 	}
 	return location.Location{
 		Line:     l + 1,
 		Column:   pos - d,
 		Position: pos,
+		Offset:   o,
 	}
 }
 
 func (b *buffer) newlines() []int {
 	if b.nls == nil {
-		n := []int{}
+		n, no := []int{}, []int{}
+		o := 0
 		for p, r := range b.input {
 			if r == '\n' {
 				n = append(n, p)
+				no = append(no, o)
 			}
+			o += len(string(r))
 		}
-		b.nls = n
+		b.nls, b.nlo = n, no
 	}
 	return b.nls
 }
@@ -55,13 +55,20 @@ func TestStringBufferUnicode(t *testing.T) {
 func TestStringBufferLocation(t *testing.T) {
 	s := "lots\nof text\nin multiple lines\nand more,\nmore, much more"
 	b := StringBuffer(s)
-	assert.EqualValues(t, location.New(1, 1, 0), b.Location(0))
-	assert.EqualValues(t, location.New(1, 2, 1), b.Location(1))
-	assert.EqualValues(t, location.New(2, 6, 10), b.Location(10))
-	assert.EqualValues(t, location.New(3, 8, 20), b.Location(20))
-	assert.EqualValues(t, location.New(2, 7, 11), b.Location(11))
-	assert.EqualValues(t, location.New(2, 8, 12), b.Location(12))
-	assert.EqualValues(t, location.New(3, 1, 13), b.Location(13))
+	assert.EqualValues(t, ascii(1, 1, 0), b.Location(0))
+	assert.EqualValues(t, ascii(1, 2, 1), b.Location(1))
+	assert.EqualValues(t, ascii(2, 6, 10), b.Location(10))
+	assert.EqualValues(t, ascii(3, 8, 20), b.Location(20))
+	assert.EqualValues(t, ascii(2, 7, 11), b.Location(11))
+	assert.EqualValues(t, ascii(2, 8, 12), b.Location(12))
+	assert.EqualValues(t, ascii(3, 1, 13), b.Location(13))
+}
+
+// ascii returns the location of an ASCII input position
+func ascii(line, column, position int) location.Location {
+	l := location.New(line, column, position)
+	l.Offset = position
+	return l
 }
 
 func TestStringBufferLine(t *testing.T) {
@@ -0,0 +1,231 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package buffer
+
+import (
+	"bufio"
+	"io"
+	"sort"
+
+	"github.com/lalloni/seared/location"
+)
+
+// Stream is a buffer reading its input lazily which can discard the input
+// that is no longer needed.
+type Stream interface {
+	Buffer
+	// Release discards the input before pos, which must not be accessed
+	// anymore.
+	Release(pos int)
+	// Err returns the error other than io.EOF that stopped reading the input,
+	// if any.
+	Err() error
+}
+
+type readerBuffer struct {
+	reader io.RuneReader
+	err    error
+	eof    bool
+	// window holds the input retained from position base on
+	window []rune
+	base   int
+	// nls are the positions of the newlines in the window
+	nls []int
+	// lines is the number of released newlines and last the position of the
+	// last one or -1
+	lines int
+	last  int
+	// offset is the byte offset of base
+	offset int
+}
+
+// ReaderBuffer returns a buffer decoding the UTF-8 input read from r as it is
+// needed, keeping only the input from the lowest position not yet released.
+// Released input reads as if it were missing and its locations have unknown
+// offset, as well as unknown line and column unless they are on the first line
+// retained.
+//
+// Length reads the whole input, so Has should be used instead to test for
+// the end of input.
+func ReaderBuffer(r io.Reader) Stream {
+	rr, ok := r.(io.RuneReader)
+	if !ok {
+		rr = bufio.NewReader(r)
+	}
+	return &readerBuffer{reader: rr, last: -1}
+}
+
+// fill reads the input up to pos
+func (b *readerBuffer) fill(pos int) {
+	for !b.eof && pos >= b.base+len(b.window) {
+		r, _, err := b.reader.ReadRune()
+		if err != nil {
+			if err != io.EOF {
+				b.err = err
+			}
+			b.eof = true
+			return
+		}
+		if r == '\n' {
+			b.nls = append(b.nls, b.base+len(b.window))
+		}
+		b.window = append(b.window, r)
+	}
+}
+
+func (b *readerBuffer) Release(pos int) {
+	for pos > b.base {
+		if len(b.window) == 0 {
+			if b.fill(b.base); len(b.window) == 0 {
+				return
+			}
+		}
+		n := pos - b.base
+		if n > len(b.window) {
+			n = len(b.window)
+		}
+		b.drop(n)
+	}
+}
+
+// drop discards the first n runes of the window
+func (b *readerBuffer) drop(n int) {
+	l := sort.SearchInts(b.nls, b.base+n)
+	if l > 0 {
+		b.lines += l
+		b.last = b.nls[l-1]
+		b.nls = b.nls[l:]
+	}
+	for _, r := range b.window[:n] {
+		b.offset += len(string(r))
+	}
+	b.window = b.window[n:]
+	b.base += n
+}
+
+func (b *readerBuffer) Err() error {
+	return b.err
+}
+
+func (b *readerBuffer) Decode(pos int) (rune, int) {
+	if b.Has(pos) {
+		return b.window[pos-b.base], pos + 1
+	}
+	return 0, pos
+}
+
+func (b *readerBuffer) Has(pos int) bool {
+	b.fill(pos)
+	return pos >= b.base && pos < b.base+len(b.window)
+}
+
+func (b *readerBuffer) Rune(pos int) rune {
+	if b.Has(pos) {
+		return b.window[pos-b.base]
+	}
+	return 0
+}
+
+func (b *readerBuffer) Runes(start, end int) []rune {
+	if start < b.base {
+		start = b.base
+	}
+	if end <= start {
+		return nil
+	}
+	b.fill(end - 1)
+	l := b.base + len(b.window)
+	if start >= l {
+		return nil
+	}
+	if end > l {
+		end = l
+	}
+	return b.window[start-b.base : end-b.base]
+}
+
+func (b *readerBuffer) String(start, end int) string {
+	return string(b.Runes(start, end))
+}
+
+// Input returns the input retained.
+func (b *readerBuffer) Input() string {
+	return string(b.window)
+}
+
+func (b *readerBuffer) Length() int {
+	for !b.eof {
+		b.fill(b.base + len(b.window))
+	}
+	return b.base + len(b.window)
+}
+
+func (b *readerBuffer) Reader(pos int) io.RuneReader {
+	return &bufferReader{b, pos}
+}
+
+// Line returns the text of line n when it is retained.
+func (b *readerBuffer) Line(n int) string {
+	i := n - b.lines - 1
+	if i < 0 || i > len(b.nls) {
+		return ""
+	}
+	start := b.last + 1
+	if i > 0 {
+		start = b.nls[i-1] + 1
+	}
+	if start < b.base {
+		return ""
+	}
+	if i < len(b.nls) {
+		return b.String(start, b.nls[i])
+	}
+	return b.String(start, b.Length())
+}
+
+func (b *readerBuffer) Location(pos int) location.Location {
+	if pos < b.base {
+		if pos <= b.last {
+			return location.Location{Position: pos}
+		}
+		return location.Location{Line: b.lines + 1, Column: pos - b.last, Position: pos}
+	}
+	l := sort.SearchInts(b.nls, pos)
+	d := b.last
+	if l > 0 {
+		d = b.nls[l-1]
+	}
+	o := b.offset
+	for _, r := range b.Runes(b.base, pos) {
+		o += len(string(r))
+	}
+	return location.Location{
+		Line:     b.lines + l + 1,
+		Column:   pos - d,
+		Position: pos,
+		Offset:   o,
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package buffer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lalloni/seared/location"
+)
+
+func TestReaderBuffer(t *testing.T) {
+	a := assert.New(t)
+	b := ReaderBuffer(strings.NewReader("aaŧ←\n↓ŋħ\n5ł"))
+	a.EqualValues('ŧ', b.Rune(2))
+	a.EqualValues("←\n↓", b.String(3, 6))
+	a.True(b.Has(10))
+	a.False(b.Has(11))
+	a.EqualValues(0, b.Rune(11))
+	a.Equal("↓ŋħ", b.Line(2))
+	a.Equal(location.Location{Line: 2, Column: 2, Position: 6, Offset: 11}, b.Location(6))
+
+	b.Release(6)
+	a.EqualValues(0, b.Rune(5))
+	a.EqualValues('ŋ', b.Rune(6))
+	a.EqualValues("ŋħ", b.String(4, 8))
+	a.Equal("ŋħ\n5ł", b.Input())
+	a.Equal("", b.Line(1))
+	a.Equal("", b.Line(2))
+	a.Equal("5ł", b.Line(3))
+	a.Equal(location.Location{Line: 2, Column: 3, Position: 7, Offset: 13}, b.Location(7))
+	a.Equal(location.Location{Line: 3, Column: 2, Position: 10, Offset: 17}, b.Location(10))
+	a.Equal(location.Location{Position: 2}, b.Location(2))
+	a.Equal(11, b.Length())
+	a.NoError(b.Err())
+}
+
+func TestReaderBufferLazy(t *testing.T) {
+	a := assert.New(t)
+	r := &countingReader{reader: strings.NewReader(strings.Repeat("x", 10000))}
+	b := ReaderBuffer(r)
+	a.True(b.Has(10))
+	a.True(r.read < 10000)
+	b.Release(9000)
+	a.True(b.Has(9999))
+	a.False(b.Has(10000))
+	a.Equal(1000, len(b.Input()))
+}
+
+func TestReaderBufferError(t *testing.T) {
+	a := assert.New(t)
+	failure := errors.New("failure")
+	b := ReaderBuffer(io.MultiReader(strings.NewReader("abc"), &failingReader{failure}))
+	a.Equal(3, b.Length())
+	a.Equal(failure, b.Err())
+}
+
+type countingReader struct {
+	reader io.Reader
+	read   int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
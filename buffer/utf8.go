@@ -0,0 +1,140 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package buffer
+
+import (
+	"io"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/lalloni/seared/location"
+)
+
+type utf8Buffer struct {
+	input string
+	// nls are the byte offsets of the newlines and nlr their rune positions
+	nls []int
+	nlr []int
+}
+
+// UTF8Buffer returns a buffer working directly on the UTF-8 encoded input,
+// whose positions are byte offsets. The locations of its positions provide
+// both the byte offset and the rune position.
+func UTF8Buffer(input string) Buffer {
+	return &utf8Buffer{input: input}
+}
+
+func (b *utf8Buffer) Length() int {
+	return len(b.input)
+}
+
+func (b *utf8Buffer) Has(pos int) bool {
+	return pos >= 0 && pos < len(b.input)
+}
+
+func (b *utf8Buffer) Decode(pos int) (rune, int) {
+	if b.Has(pos) {
+		r, n := utf8.DecodeRuneInString(b.input[pos:])
+		return r, pos + n
+	}
+	return 0, pos
+}
+
+func (b *utf8Buffer) Rune(pos int) rune {
+	r, _ := b.Decode(pos)
+	return r
+}
+
+func (b *utf8Buffer) Runes(start, end int) []rune {
+	return []rune(b.String(start, end))
+}
+
+func (b *utf8Buffer) String(start, end int) string {
+	l := len(b.input)
+	if start >= l || end <= start {
+		return ""
+	}
+	if end > l {
+		end = l
+	}
+	return b.input[start:end]
+}
+
+func (b *utf8Buffer) Input() string {
+	return b.input
+}
+
+func (b *utf8Buffer) Reader(pos int) io.RuneReader {
+	return &bufferReader{b, pos}
+}
+
+func (b *utf8Buffer) Line(n int) string {
+	nls := b.newlines()
+	start := 0
+	if n > 1 {
+		start = nls[n-2] + 1
+	}
+	end := len(b.input)
+	if n <= len(nls) {
+		end = nls[n-1]
+	}
+	return b.String(start, end)
+}
+
+func (b *utf8Buffer) Location(pos int) location.Location {
+	nls := b.newlines()
+	l := sort.SearchInts(nls, pos)
+	start, r := 0, 0
+	if l > 0 {
+		start, r = nls[l-1]+1, b.nlr[l-1]+1
+	}
+	column := utf8.RuneCountInString(b.String(start, pos)) + 1
+	if pos > len(b.input) {
+		column += pos - len(b.input)
+	}
+	return location.Location{
+		Line:     l + 1,
+		Column:   column,
+		Position: r + column - 1,
+		Offset:   pos,
+	}
+}
+
+func (b *utf8Buffer) newlines() []int {
+	if b.nls == nil {
+		n, nr := []int{}, []int{}
+		r := 0
+		for p, c := range b.input {
+			if c == '\n' {
+				n = append(n, p)
+				nr = append(nr, r)
+			}
+			r++
+		}
+		b.nls, b.nlr = n, nr
+	}
+	return b.nls
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package buffer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lalloni/seared/location"
+)
+
+func TestUTF8Buffer(t *testing.T) {
+	a := assert.New(t)
+	s := "aaŧ←\n↓ŋħ\n5ł"
+	b := UTF8Buffer(s)
+	a.Equal(s, b.Input())
+	a.Equal(len(s), b.Length())
+	r, next := b.Decode(2)
+	a.EqualValues('ŧ', r)
+	a.Equal(4, next)
+	a.EqualValues('←', b.Rune(4))
+	a.Equal("ŧ←", b.String(2, 7))
+	a.Equal([]rune("ŧ←"), b.Runes(2, 7))
+	a.Equal("5ł", b.String(16, 100))
+	a.Equal("", b.String(100, 101))
+	a.True(b.Has(17))
+	a.False(b.Has(19))
+	r, next = b.Decode(19)
+	a.EqualValues(0, r)
+	a.Equal(19, next)
+	a.Equal("↓ŋħ", b.Line(2))
+	a.Equal("5ł", b.Line(3))
+}
+
+func TestUTF8BufferLocation(t *testing.T) {
+	a := assert.New(t)
+	b := UTF8Buffer("aaŧ←\n↓ŋħ\n5ł")
+	a.Equal(location.Location{Line: 1, Column: 1, Position: 0, Offset: 0}, b.Location(0))
+	a.Equal(location.Location{Line: 1, Column: 4, Position: 3, Offset: 4}, b.Location(4))
+	a.Equal(location.Location{Line: 2, Column: 2, Position: 6, Offset: 11}, b.Location(11))
+	a.Equal(location.Location{Line: 3, Column: 2, Position: 10, Offset: 17}, b.Location(17))
+	a.Equal(location.Location{Line: 3, Column: 3, Position: 11, Offset: 19}, b.Location(19))
+	a.Equal(StringBuffer("aaŧ←\n↓ŋħ\n5ł").Location(6), b.Location(11))
+}
+
+func TestUTF8BufferRuneReader(t *testing.T) {
+	a := assert.New(t)
+	s := "lħs\nof¶↓n€ß"
+	r := UTF8Buffer(s).Reader(0)
+	for _, ru := range s {
+		rr, n, err := r.ReadRune()
+		a.Nil(err)
+		a.EqualValues(ru, rr)
+		a.Equal(len(string(ru)), n)
+	}
+	_, _, err := r.ReadRune()
+	a.EqualValues(io.EOF, err)
+}
@@ -0,0 +1,77 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Searedgen generates a Go matcher from a grammar written in PEG notation.
+//
+// Usage:
+//
+//	searedgen [-package name] [-name name] [-o file] grammar.peg
+//
+// It is meant to be used from go:generate directives like:
+//
+//	//go:generate go run github.com/lalloni/seared/cmd/searedgen -package calc -o calc_parser.go calc.peg
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lalloni/seared/peg"
+)
+
+func main() {
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "name of the package of the generated file")
+	name := flag.String("name", "", "name of the generated parser (defaults to the grammar main rule)")
+	out := flag.String("o", "", "output file (defaults to the grammar file with a .go extension)")
+	flag.Parse()
+	if flag.NArg() != 1 || *pkg == "" {
+		fmt.Fprintln(os.Stderr, "usage: searedgen [-package name] [-name name] [-o file] grammar.peg")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	in := flag.Arg(0)
+	if *out == "" {
+		*out = strings.TrimSuffix(in, filepath.Ext(in)) + ".go"
+	}
+	source, err := os.ReadFile(in)
+	if err != nil {
+		fail(err)
+	}
+	src, err := peg.Generate(string(source), *pkg, *name)
+	if err != nil {
+		fail(fmt.Errorf("%s: %v", in, err))
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "searedgen:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,121 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package seared
+
+import (
+	"strings"
+
+	"github.com/lalloni/seared/buffer"
+	"github.com/lalloni/seared/location"
+)
+
+// SyntaxError describes an error found in the input while parsing
+type SyntaxError struct {
+	// Label is the label thrown by a Throw expression, if any
+	Label string
+	// Rule is the name of the rule where the error was found
+	Rule string
+	// Location is where the error was found in the input
+	Location location.Location
+	// Found is the input found at Location
+	Found string
+	// Expected describes what was expected at Location
+	Expected string
+	// position is where the error was found in the units of the input buffer
+	position int
+}
+
+// NewLabeledError returns the syntax error raised by throwing label in rule at
+// pos of input.
+func NewLabeledError(label, rule string, input buffer.Buffer, pos int) *SyntaxError {
+	return &SyntaxError{Label: label, Rule: rule, Location: input.Location(pos), position: pos}
+}
+
+func (e *SyntaxError) Error() string {
+	var s string
+	if e.Label != "" {
+		s = e.Label + " at " + e.Location.String()
+	} else {
+		s = "Invalid input '" + e.Found + "' at " + e.Location.String() + ", expected " + e.Expected
+	}
+	if e.Rule != "" {
+		s += " in rule " + e.Rule
+	}
+	return s
+}
+
+// GrammarError describes a defect of a grammar found while building its
+// parser
+type GrammarError struct {
+	// Defect is the kind of defect found
+	Defect Defect
+	// Path is the names of the parser and the rules leading to the expression
+	// having the defect
+	Path []string
+	// Expression describes the expression having the defect
+	Expression string
+	// Problem describes the defect
+	Problem string
+}
+
+func (e *GrammarError) Error() string {
+	s := e.Problem
+	if e.Expression != "" {
+		s += " in " + e.Expression
+	}
+	if len(e.Path) > 0 {
+		s += " at " + strings.Join(e.Path, " > ")
+	}
+	return s
+}
+
+// Defect is a kind of grammar defect
+type Defect int
+
+const (
+	// NullableRepetition is a repetition of an expression that may match
+	// empty input, which would loop forever
+	NullableRepetition Defect = iota
+	// LeftRecursion is a cycle of rules applying each other at the same input
+	// position
+	LeftRecursion
+	// UnreachableRule is a rule never applied by the parser
+	UnreachableRule
+	// ShadowedAlternative is a choice alternative never tried since an
+	// earlier one always matches instead
+	ShadowedAlternative
+)
+
+// GrammarErrors are all the defects found in a grammar
+type GrammarErrors []*GrammarError
+
+func (es GrammarErrors) Error() string {
+	ss := []string{}
+	for _, e := range es {
+		ss = append(ss, e.Error())
+	}
+	return strings.Join(ss, "; ")
+}
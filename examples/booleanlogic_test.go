@@ -29,6 +29,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/lalloni/seared/node"
 )
 
 func TestBooleanLogicParser(t *testing.T) {
@@ -59,3 +61,32 @@ func TestBooleanLogicParser(t *testing.T) {
 		a.Equal(c.e, r.Success)
 	}
 }
+
+func TestBooleanLogicConcrete(t *testing.T) {
+	a := assert.New(t)
+	p := BooleanExpressionParser()
+	abstract := p.ParseString("F | T &\tT ")
+	p.SetConcrete(true)
+	for _, s := range []string{"T", "F | T &\tT ", "F|T&\n T  "} {
+		r := p.ParseString(s)
+		if a.True(r.Success, s) && a.Len(r.Nodes, 1) {
+			a.Equal(s, r.Nodes[0].Source())
+			parsed, err := node.Parse(r.Nodes[0].FormatSpans())
+			if a.NoError(err) {
+				a.Equal(r.Nodes[0], parsed)
+				a.Equal(s, parsed.Source())
+			}
+		}
+	}
+	concrete := p.ParseString("F | T &\tT ")
+	a.NotEqual(abstract.FormatNodeTree(), concrete.FormatNodeTree())
+	for _, n := range concrete.Nodes {
+		node.Inspect(n, func(n *node.Node) bool {
+			if n != nil {
+				n.Leading, n.Trailing = nil, nil
+			}
+			return true
+		})
+	}
+	a.Equal(abstract.FormatNodeTree(), concrete.FormatNodeTree())
+}
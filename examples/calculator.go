@@ -23,6 +23,8 @@
 // ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
 // POSSIBILITY OF SUCH DAMAGE.
 
+//go:generate go run generate.go
+
 package examples
 
 import "github.com/lalloni/seared"
@@ -37,7 +39,7 @@ func Digit(b *seared.Builder) seared.Expression {
 
 func Number(b *seared.Builder) seared.Expression {
 	return b.Rule(func() seared.Expression {
-		return b.OneOrMore(Digit(b))
+		return b.Token(b.OneOrMore(Digit(b)))
 	})
 }
 
@@ -0,0 +1,48 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package examples
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculatorGenerated(t *testing.T) {
+	a := assert.New(t)
+	parser := CalculatorParser()
+	for _, expression := range []string{"1", "10*(2+1)", "1*10+1", "a20", "", "1*10+a", "2*1+(1+1*a)*2"} {
+		expected := parser.ParseString(expression)
+		actual := ParseCalculator(expression)
+		a.Equal(expected.Success, actual.Success, expression)
+		a.Equal(expected.End, actual.End, expression)
+		a.Equal(expected.FormatNodeTree(), actual.FormatNodeTree(), expression)
+		a.Equal(expected.Values, actual.Values, expression)
+		a.Equal(expected.Nodes, actual.Nodes, expression)
+		a.Equal(expected.FormatResultTree(), actual.FormatResultTree(), expression)
+		a.Equal(expected.BetterError(), actual.BetterError(), expression)
+	}
+}
@@ -26,6 +26,7 @@
 package examples
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -65,3 +66,36 @@ func TestCalculatorRecognizing(t *testing.T) {
 		}
 	}
 }
+
+func TestCalculatorAnalyze(t *testing.T) {
+	assert.Empty(t, CalculatorParser().Analyze())
+	assert.Empty(t, EvaluatorParser().Analyze())
+	assert.Empty(t, BooleanExpressionParser().Analyze())
+}
+
+func TestCalculatorGrammar(t *testing.T) {
+	assert.Equal(t, `Calculator <- Sum !.
+Sum <- Term ([+-] Term)*
+Term <- Factor ([*/] Factor)*
+Factor <- Number / Parenthesis
+Number <- <Digit+>
+Digit <- [0-9]
+Parenthesis <- '(' Sum ')'
+`, CalculatorParser().Grammar().String())
+}
+
+// benchmarkExpression is a long arithmetic expression for benchmarking the
+// calculator grammars.
+var benchmarkExpression = strings.Repeat("(12*34+5)/7-89*(1+2)+", 100) + "1"
+
+// BenchmarkCalculator parses with a grammar without actions, which must not
+// pay for computing values.
+func BenchmarkCalculator(b *testing.B) {
+	parser := CalculatorParser()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if result := parser.ParseString(benchmarkExpression); !result.Success || result.Values != nil {
+			b.Fatal("unexpected result parsing benchmark expression")
+		}
+	}
+}
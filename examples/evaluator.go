@@ -0,0 +1,101 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package examples
+
+import (
+	"strconv"
+
+	"github.com/lalloni/seared"
+)
+
+// ----------------- Rules -----------------------------------------------------
+
+func EvalNumber(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Action(b.OneOrMore(Digit(b)), func(r *seared.Result, _ []interface{}) interface{} {
+			n, _ := strconv.Atoi(r.Match())
+			return n
+		})
+	})
+}
+
+func EvalParenthesis(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Action(b.Sequence(b.Rune('('), EvalSum(b), b.Rune(')')), func(_ *seared.Result, vs []interface{}) interface{} {
+			return vs[1]
+		})
+	})
+}
+
+func EvalFactor(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Choice(EvalNumber(b), EvalParenthesis(b))
+	})
+}
+
+func EvalTerm(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Action(b.Sequence(EvalFactor(b), b.ZeroOrMore(b.AnyOf("*/"), EvalFactor(b))), fold)
+	})
+}
+
+func EvalSum(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Action(b.Sequence(EvalTerm(b), b.ZeroOrMore(b.AnyOf("+-"), EvalTerm(b))), fold)
+	})
+}
+
+func Evaluator(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(EvalSum(b), b.End())
+	})
+}
+
+// fold applies the operators found between the operands in values from left
+// to right.
+func fold(_ *seared.Result, values []interface{}) interface{} {
+	n := values[0].(int)
+	for i := 1; i < len(values); i += 2 {
+		m := values[i+1].(int)
+		switch values[i] {
+		case "+":
+			n += m
+		case "-":
+			n -= m
+		case "*":
+			n *= m
+		case "/":
+			n /= m
+		}
+	}
+	return n
+}
+
+// EvaluatorParser returns a parser of the calculator grammar whose result
+// value is the value of the arithmetic expression parsed.
+func EvaluatorParser() *seared.Parser {
+	return seared.NewParser(Evaluator)
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package examples
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluator(t *testing.T) {
+	a := assert.New(t)
+	parser := EvaluatorParser()
+	cases := []struct {
+		expression string
+		value      int
+	}{
+		{"1", 1},
+		{"2+1*3", 5},
+		{"10*(2+1)", 30},
+		{"10-2-3", 5},
+		{"2*(3+4)/7-1", 1},
+	}
+	for _, c := range cases {
+		result := parser.ParseString(c.expression)
+		if a.True(result.Success, c.expression) {
+			a.Equal(c.value, result.Value(), c.expression)
+		}
+	}
+	a.Nil(parser.ParseString("2+").Value())
+}
+
+func BenchmarkEvaluator(b *testing.B) {
+	parser := EvaluatorParser()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if result := parser.ParseString(benchmarkExpression); !result.Success || result.Value() == nil {
+			b.Fatal("unexpected result evaluating benchmark expression")
+		}
+	}
+}
@@ -26,6 +26,8 @@
 package seared
 
 import (
+	"strings"
+
 	"github.com/lalloni/seared/buffer"
 )
 
@@ -42,6 +44,21 @@ type expression struct {
 	expectation string
 	parser      *Parser
 	matcher     Matcher
+	// operands are the expressions this one is composed of
+	operands []Expression
+	// argument is the parameter of terminal expressions: the rune of Rune, the
+	// text of Literal and AnyOf, the bounds of Range and the label of Throw
+	argument interface{}
+	// predictions and dispatch tell which alternatives of a choice may match
+	// at each input character
+	predictions []prediction
+	dispatch    *dispatch
+}
+
+// throwArgument is the argument of Throw expressions
+type throwArgument struct {
+	label string
+	rule  string
 }
 
 func newExpression(name, expectation string, p *Parser, m Matcher) *expression {
@@ -53,6 +70,31 @@ func newExpression(name, expectation string, p *Parser, m Matcher) *expression {
 	}
 }
 
+// NewExpression returns an expression named name applying the matcher m, as
+// used by generated parsers to describe their expressions.
+func NewExpression(name, expectation string, m Matcher) Expression {
+	return newExpression(name, expectation, nil, m)
+}
+
+// newSkipped returns the Skipped expression failing in place of alternatives
+// of a choice not applied since they could not match at the input position,
+// whose failures are those of leaves, only built by Result.ChildlessResults.
+func newSkipped(leaves []Expression) *expression {
+	return newExpression("Skipped", strings.Join(expectations(leaves), " or "), nil, nil).with(leaves, nil)
+}
+
+// NewSkipped returns the Skipped expression for the leaves of alternatives
+// of a choice, as used by generated parsers to skip them.
+func NewSkipped(leaves ...Expression) Expression {
+	return newSkipped(leaves)
+}
+
+func (r *expression) with(operands []Expression, argument interface{}) *expression {
+	r.operands = operands
+	r.argument = argument
+	return r
+}
+
 func (r *expression) Name() string {
 	return r.name
 }
@@ -0,0 +1,376 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package seared
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lalloni/seared/buffer"
+)
+
+// CharSet is a set of characters made of sorted, disjoint and non adjacent
+// ranges of characters.
+type CharSet [][2]rune
+
+// Contains tells whether c belongs to the set.
+func (cs CharSet) Contains(c rune) bool {
+	i := sort.Search(len(cs), func(i int) bool { return cs[i][1] >= c })
+	return i < len(cs) && cs[i][0] <= c
+}
+
+func (cs CharSet) String() string {
+	ss := []string{}
+	for _, r := range cs {
+		s := strconv.QuoteRune(r[0])
+		if r[1] != r[0] {
+			s += "-" + strconv.QuoteRune(r[1])
+		}
+		ss = append(ss, s)
+	}
+	return "[" + strings.Join(ss, " ") + "]"
+}
+
+// union returns the set of the characters of cs or other.
+func (cs CharSet) union(other CharSet) CharSet {
+	all := append(append(CharSet{}, cs...), other...)
+	sort.Slice(all, func(i, j int) bool { return all[i][0] < all[j][0] })
+	u := CharSet{}
+	for _, r := range all {
+		if n := len(u); n > 0 && r[0] <= u[n-1][1]+1 {
+			if r[1] > u[n-1][1] {
+				u[n-1][1] = r[1]
+			}
+			continue
+		}
+		u = append(u, r)
+	}
+	return u
+}
+
+// First returns the set of characters a match of e consuming input may start
+// with, and whether e may succeed without consuming input.
+func First(e Expression) (CharSet, bool) {
+	rules := reachableRules(e)
+	nullables := nullableRules(rules)
+	return first(e, firstRules(rules, nullables), nullables), nullable(e, nullables)
+}
+
+// firstRules returns the first characters of the matches of the rules.
+func firstRules(rules []*rule, nullables map[*rule]bool) map[*rule]CharSet {
+	firsts := map[*rule]CharSet{}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range rules {
+			f := first(r.expression, firsts, nullables)
+			if len(f) != len(firsts[r]) || f.String() != firsts[r].String() {
+				firsts[r] = f
+				changed = true
+			}
+		}
+	}
+	return firsts
+}
+
+// first returns the first characters of the matches of e given the ones of
+// the rules.
+func first(e Expression, rules map[*rule]CharSet, nullables map[*rule]bool) CharSet {
+	switch e := e.(type) {
+	case *rule:
+		return rules[e]
+	case *expression:
+		switch e.name {
+		case "Rune":
+			c := e.argument.(rune)
+			return CharSet{{c, c}}
+		case "Literal":
+			for _, c := range e.argument.(string) {
+				return CharSet{{c, c}}
+			}
+		case "Range":
+			bounds := e.argument.([2]rune)
+			return CharSet{bounds}
+		case "AnyOf":
+			cs := CharSet{}
+			for _, c := range e.argument.(string) {
+				cs = cs.union(CharSet{{c, c}})
+			}
+			return cs
+		case "Any":
+			return CharSet{{0, unicode.MaxRune}}
+		case "Optional", "ZeroOrMore", "OneOrMore", "Action", "Token", "Test":
+			return first(e.operands[0], rules, nullables)
+		case "Sequence":
+			cs := CharSet{}
+			for _, o := range e.operands {
+				cs = cs.union(first(o, rules, nullables))
+				if !nullable(o, nullables) {
+					break
+				}
+			}
+			return cs
+		case "Choice":
+			cs := CharSet{}
+			for _, o := range e.operands {
+				cs = cs.union(first(o, rules, nullables))
+			}
+			return cs
+		}
+	}
+	return CharSet{}
+}
+
+// Follow returns the set of characters that may follow a match of e in the
+// grammar of p, and whether the end of the input may.
+func (p *Parser) Follow(e Expression) (CharSet, bool) {
+	rules := reachableRules(p.main)
+	nullables := nullableRules(rules)
+	firsts := firstRules(rules, nullables)
+	expressions := []Expression{}
+	seen := map[Expression]bool{}
+	var collect func(e Expression)
+	collect = func(e Expression) {
+		if e == nil || seen[e] {
+			return
+		}
+		seen[e] = true
+		expressions = append(expressions, e)
+		for _, o := range operands(e) {
+			collect(o)
+		}
+	}
+	collect(p.main)
+	follows := map[Expression]CharSet{}
+	ends := map[Expression]bool{p.main: true}
+	for changed := true; changed; {
+		changed = false
+		add := func(e Expression, cs CharSet, end bool) {
+			u := follows[e].union(cs)
+			if u.String() != follows[e].String() || end && !ends[e] {
+				follows[e] = u
+				ends[e] = ends[e] || end
+				changed = true
+			}
+		}
+		for _, e := range expressions {
+			f, end := follows[e], ends[e]
+			x, ok := e.(*expression)
+			if !ok {
+				for _, o := range operands(e) {
+					add(o, f, end)
+				}
+				continue
+			}
+			switch x.name {
+			case "Sequence":
+				for i, o := range x.operands {
+					cs, rest := CharSet{}, true
+					for _, n := range x.operands[i+1:] {
+						cs = cs.union(first(n, firsts, nullables))
+						if !nullable(n, nullables) {
+							rest = false
+							break
+						}
+					}
+					if rest {
+						add(o, cs.union(f), end)
+					} else {
+						add(o, cs, false)
+					}
+				}
+			case "ZeroOrMore", "OneOrMore":
+				add(x.operands[0], first(x.operands[0], firsts, nullables).union(f), end)
+			case "Test", "TestNot":
+				add(x.operands[0], CharSet{{0, unicode.MaxRune}}, true)
+			default:
+				for _, o := range x.operands {
+					add(o, f, end)
+				}
+			}
+		}
+	}
+	return follows[e], ends[e]
+}
+
+// dispatch tells the alternatives of a choice that may match at each input
+// character, so the others can be skipped.
+type dispatch struct {
+	// bounds are the sorted first characters of intervals of characters at
+	// which the same alternatives may match
+	bounds []rune
+	// intervals are the alternatives that may match at each interval
+	intervals []*alternatives
+	// end are the alternatives that may match at the end of the input
+	end *alternatives
+}
+
+// alternatives are the alternatives of a choice that may match at some input
+// character
+type alternatives struct {
+	// indexes are the sorted indexes of the alternatives
+	indexes []int
+	// skipped are the Skipped expressions standing for the alternatives
+	// skipped before each of indexes and after the last one, or nil when none
+	skipped []Expression
+}
+
+// prediction describes which input a choice alternative may match
+type prediction struct {
+	// always tells whether the alternative may succeed, throw or cut without
+	// consuming input, or is made of expressions not built by a Builder
+	always bool
+	// first are the characters a match of the alternative may start with
+	first CharSet
+	// leaves are the expressions failing at the start of the alternative when
+	// it fails there, or the alternative itself when it is one
+	leaves []Expression
+}
+
+// predict returns the predictions for the operands of the choice e.
+func predict(e *expression, firsts map[*rule]CharSet, nullables map[*rule]bool) []prediction {
+	ps := []prediction{}
+	for _, o := range e.operands {
+		p := prediction{first: first(o, firsts, nullables)}
+		seen := map[Expression]bool{o: true}
+		var visit func(e Expression)
+		visit = func(e Expression) {
+			switch x := e.(type) {
+			case *rule:
+			case *expression:
+				p.always = p.always || x.name == "Cut" || x.name == "Throw" || x.parser == nil
+			default:
+				p.always = true
+			}
+			ls := leftOperands(e, nullables)
+			if len(ls) == 0 && e != o {
+				p.leaves = append(p.leaves, e)
+			}
+			for _, l := range ls {
+				if l != nil && !seen[l] {
+					seen[l] = true
+					visit(l)
+				}
+			}
+		}
+		visit(o)
+		if len(p.leaves) == 0 {
+			p.leaves = []Expression{o}
+		}
+		p.always = p.always || nullable(o, nullables)
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// newDispatch returns the dispatch for the predictions of the alternatives of
+// a choice, or nil when no alternative could be skipped.
+func newDispatch(ps []prediction) *dispatch {
+	bounds := map[rune]bool{0: true}
+	skippable := false
+	for _, p := range ps {
+		skippable = skippable || !p.always
+		for _, r := range p.first {
+			bounds[r[0]] = true
+			if r[1] < unicode.MaxRune {
+				bounds[r[1]+1] = true
+			}
+		}
+	}
+	if !skippable {
+		return nil
+	}
+	runs := map[[2]int]Expression{}
+	skipped := func(from, to int) Expression {
+		if from == to {
+			return nil
+		}
+		if runs[[2]int{from, to}] == nil {
+			leaves := []Expression{}
+			for _, p := range ps[from:to] {
+				leaves = append(leaves, p.leaves...)
+			}
+			runs[[2]int{from, to}] = newSkipped(leaves)
+		}
+		return runs[[2]int{from, to}]
+	}
+	matching := func(match func(p prediction) bool) *alternatives {
+		a := &alternatives{}
+		from := 0
+		for i, p := range ps {
+			if p.always || match(p) {
+				a.indexes = append(a.indexes, i)
+				a.skipped = append(a.skipped, skipped(from, i))
+				from = i + 1
+			}
+		}
+		a.skipped = append(a.skipped, skipped(from, len(ps)))
+		return a
+	}
+	d := &dispatch{end: matching(func(prediction) bool { return false })}
+	for b := range bounds {
+		d.bounds = append(d.bounds, b)
+	}
+	sort.Slice(d.bounds, func(i, j int) bool { return d.bounds[i] < d.bounds[j] })
+	for _, b := range d.bounds {
+		d.intervals = append(d.intervals, matching(func(p prediction) bool { return p.first.Contains(b) }))
+	}
+	return d
+}
+
+// candidates returns the alternatives that may match at pos of input.
+func (d *dispatch) candidates(input buffer.Buffer, pos int) *alternatives {
+	c, next := input.Decode(pos)
+	if next == pos {
+		return d.end
+	}
+	i := sort.Search(len(d.bounds), func(i int) bool { return d.bounds[i] > c })
+	return d.intervals[i-1]
+}
+
+// predictChoices sets the dispatch of the choices reachable from root.
+func predictChoices(root Expression) {
+	rules := reachableRules(root)
+	nullables := nullableRules(rules)
+	firsts := firstRules(rules, nullables)
+	seen := map[Expression]bool{}
+	var visit func(e Expression)
+	visit = func(e Expression) {
+		if e == nil || seen[e] {
+			return
+		}
+		seen[e] = true
+		if x, ok := e.(*expression); ok && x.name == "Choice" {
+			x.predictions = predict(x, firsts, nullables)
+			x.dispatch = newDispatch(x.predictions)
+		}
+		for _, o := range operands(e) {
+			visit(o)
+		}
+	}
+	visit(root)
+}
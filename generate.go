@@ -0,0 +1,426 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package seared
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Generate returns the Go source of a file of the package pkg holding a
+// standalone matcher for the grammar of p, with one function per rule, which
+// produces the same results as p. The matcher is exposed by the Parse<name>
+// and Parse<name>Buffer functions, where name defaults to the name of p.
+//
+// Generated matchers do not support memoization, left recursive rules,
+// actions, error recovery nor the concrete mode, so they produce no values.
+func Generate(p *Parser, pkg, name string) ([]byte, error) {
+	if name == "" {
+		name = p.Name()
+	}
+	name = identifier(name)
+	g := &generator{
+		prefix:     string(unicode.ToLower([]rune(name)[0])) + name[len(string([]rune(name)[0])):],
+		names:      map[Expression]string{},
+		used:       map[string]bool{},
+		predictive: p.predictive,
+	}
+	if p.concrete {
+		return nil, fmt.Errorf("generating %s: concrete mode is not supported", name)
+	}
+	if len(p.recoveries) > 0 {
+		return nil, fmt.Errorf("generating %s: recovery expressions are not supported", name)
+	}
+	rules := reachableRules(p.main)
+	nullables := nullableRules(rules)
+	for _, r := range rules {
+		if leftRecursive(r, nullables) {
+			return nil, fmt.Errorf("generating %s: left recursive rule %q is not supported", name, r.Name())
+		}
+		if r.sync != nil {
+			return nil, fmt.Errorf("generating %s: synchronization of rule %q is not supported", name, r.Name())
+		}
+	}
+	if err := g.check(p.main, map[Expression]bool{}); err != nil {
+		return nil, fmt.Errorf("generating %s: %v", name, err)
+	}
+
+	var body bytes.Buffer
+	for _, r := range rules {
+		g.rules = append(g.rules, r)
+		if _, ok := r.expression.(*rule); !ok {
+			g.function(&body, g.matcherName(r), r.expression, "// "+r.Name()+" <- "+r.expression.Expectation())
+		}
+	}
+	main := g.name(p.main)
+	if _, ok := p.main.(*rule); !ok {
+		g.function(&body, g.prefix+"Match", p.main, "")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by seared. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	fmt.Fprintf(&out, "import (\n\"github.com/lalloni/seared\"\n\"github.com/lalloni/seared/buffer\"\n")
+	if g.nodes {
+		fmt.Fprintf(&out, "\"github.com/lalloni/seared/node\"\n")
+	}
+	fmt.Fprintf(&out, ")\n\n")
+	fmt.Fprintf(&out, "// Parse%s parses input with the %s grammar.\nfunc Parse%s(input string) *seared.Result {\nreturn Parse%sBuffer(buffer.UTF8Buffer(input))\n}\n\n", name, name, name, name)
+	fmt.Fprintf(&out, "// Parse%sBuffer parses input with the %s grammar.\nfunc Parse%sBuffer(input buffer.Buffer) *seared.Result {\nreturn %s.Apply(input, 0)\n}\n\n", name, name, name, main)
+	fmt.Fprintf(&out, "var (\n")
+	for _, r := range g.rules {
+		fmt.Fprintf(&out, "%s seared.Rule\n", g.names[r])
+	}
+	for _, e := range append(g.expressions, g.skipped...) {
+		fmt.Fprintf(&out, "%s seared.Expression\n", g.names[e])
+	}
+	fmt.Fprintf(&out, ")\n\nfunc init() {\n")
+	for _, e := range g.expressions {
+		m := "nil"
+		if g.matchers[e] != "" {
+			m = g.matchers[e]
+		}
+		fmt.Fprintf(&out, "%s = seared.NewExpression(%q, %q, %s)\n", g.names[e], e.Name(), e.Expectation(), m)
+	}
+	for _, e := range g.skipped {
+		leaves := []string{}
+		for _, l := range e.operands {
+			leaves = append(leaves, g.names[l])
+		}
+		fmt.Fprintf(&out, "%s = seared.NewSkipped(%s)\n", g.names[e], strings.Join(leaves, ", "))
+	}
+	for _, r := range g.rules {
+		fmt.Fprintf(&out, "%s = seared.NewRule(%q, nil)\n", g.names[r], r.Name())
+	}
+	for _, r := range g.rules {
+		fmt.Fprintf(&out, "%s.SetExpression(%s)\n", g.names[r], g.name(r.expression))
+		if r.dropNode {
+			fmt.Fprintf(&out, "%s.SetDropNode(true)\n", g.names[r])
+		}
+		if r.omitNode {
+			fmt.Fprintf(&out, "%s.SetOmitNode(true)\n", g.names[r])
+		}
+		if s := r.shapes(); s != 0 {
+			fmt.Fprintf(&out, "%s.SetShaping(%s)\n", g.names[r], "seared."+strings.Replace(s.String(), "|", " | seared.", -1))
+		}
+	}
+	fmt.Fprintf(&out, "}\n\n")
+	out.Write(body.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// matching returns the condition telling whether the character c decoded at
+// s up to next belongs to cs.
+func matching(cs CharSet, c, next, s string) string {
+	conditions := []string{}
+	for _, r := range cs {
+		if r[0] == r[1] {
+			conditions = append(conditions, fmt.Sprintf("%s == %s", c, strconv.QuoteRune(r[0])))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("%s >= %s && %s <= %s", c, strconv.QuoteRune(r[0]), c, strconv.QuoteRune(r[1])))
+		}
+	}
+	if len(conditions) == 0 {
+		return "false"
+	}
+	return fmt.Sprintf("%s > %s && (%s)", next, s, strings.Join(conditions, " || "))
+}
+
+// identifier returns s with the characters not allowed in Go identifiers
+// replaced by underscores.
+func identifier(s string) string {
+	rs := []rune(s)
+	for i, r := range rs {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			rs[i] = '_'
+		}
+	}
+	return string(rs)
+}
+
+type generator struct {
+	prefix      string
+	names       map[Expression]string
+	used        map[string]bool
+	rules       []*rule
+	expressions []*expression
+	skipped     []*expression
+	matchers    map[*expression]string
+	nodes       bool
+	vars        int
+	predictive  bool
+}
+
+// check verifies that every expression reachable from e can be generated
+func (g *generator) check(e Expression, seen map[Expression]bool) error {
+	if seen[e] {
+		return nil
+	}
+	seen[e] = true
+	switch e := e.(type) {
+	case *rule:
+	case *expression:
+		switch e.name {
+		case "Empty", "End", "Rune", "Literal", "Range", "Any", "AnyOf", "Sequence", "Choice",
+			"ZeroOrMore", "OneOrMore", "Optional", "Test", "TestNot", "Cut", "Throw", "Token":
+		default:
+			return fmt.Errorf("unsupported expression %s", e.Name())
+		}
+	default:
+		return fmt.Errorf("unsupported expression %T", e)
+	}
+	for _, o := range operands(e) {
+		if err := g.check(o, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name returns the name of the variable holding the description of e
+func (g *generator) name(e Expression) string {
+	if n, ok := g.names[e]; ok {
+		return n
+	}
+	var n string
+	switch e := e.(type) {
+	case *rule:
+		n = g.unique(g.prefix + "Rule" + identifier(e.Name()))
+	case *expression:
+		n = g.unique(g.prefix + e.Name())
+		if e.name == "Skipped" {
+			for _, l := range e.operands {
+				g.name(l)
+			}
+			g.skipped = append(g.skipped, e)
+		} else {
+			g.expressions = append(g.expressions, e)
+		}
+	}
+	g.names[e] = n
+	return n
+}
+
+func (g *generator) matcherName(r *rule) string {
+	return g.unique(g.prefix + "Match" + identifier(r.Name()))
+}
+
+func (g *generator) unique(n string) string {
+	u := n
+	for i := 2; g.used[u]; i++ {
+		u = n + strconv.Itoa(i)
+	}
+	g.used[u] = true
+	return u
+}
+
+func (g *generator) next(kind string) string {
+	g.vars++
+	return kind + strconv.Itoa(g.vars)
+}
+
+// function writes a function matching e named n
+func (g *generator) function(w *bytes.Buffer, n string, e Expression, comment string) {
+	if g.matchers == nil {
+		g.matchers = map[*expression]string{}
+	}
+	g.matchers[e.(*expression)] = n
+	g.name(e)
+	g.vars = 0
+	if comment != "" {
+		fmt.Fprintln(w, strings.Replace(comment, "\n", " ", -1))
+	}
+	fmt.Fprintf(w, "func %s(input buffer.Buffer, start int) *seared.Result {\n", n)
+	r := g.emit(w, e, "start")
+	fmt.Fprintf(w, "return %s\n}\n\n", r)
+}
+
+// emit writes the statements matching e at the position held by the variable
+// s and returns the name of the variable holding the result.
+func (g *generator) emit(w *bytes.Buffer, e Expression, s string) string {
+	r := g.next("r")
+	if e, ok := e.(*rule); ok {
+		fmt.Fprintf(w, "%s := %s.Apply(input, %s)\n", r, g.name(e), s)
+		return r
+	}
+	x := e.(*expression)
+	d := g.name(x)
+	fmt.Fprintf(w, "var %s *seared.Result\n", r)
+	success := func(end string) string {
+		return fmt.Sprintf("%s = seared.Success(%s, input, %s, %s)", r, d, s, end)
+	}
+	failure := func(end string) string {
+		return fmt.Sprintf("%s = seared.Failure(%s, input, %s, %s)", r, d, s, end)
+	}
+	terminal := func(condition, end, value string) {
+		g.nodes = true
+		fmt.Fprintf(w, "if %s {\n%s.WithNodes(node.NewTerminal(%s).WithSpan(%s, %s))\n} else {\n%s\n}\n", condition, success(end), value, s, end, failure(s))
+	}
+	switch x.name {
+	case "Empty":
+		fmt.Fprintln(w, success(s))
+	case "End":
+		fmt.Fprintf(w, "if !input.Has(%s) {\n%s\n} else {\n%s\n}\n", s, success(s), failure(s))
+	case "Rune":
+		c := x.argument.(rune)
+		terminal(fmt.Sprintf("c, next := input.Decode(%s); next > %s && c == %s", s, s, strconv.QuoteRune(c)), "next", strconv.Quote(string(c)))
+	case "Literal":
+		l := x.argument.(string)
+		end := g.next("end")
+		fmt.Fprintf(w, "%s := %s\nfor _, c := range %s {\n", end, s, strconv.Quote(l))
+		fmt.Fprintf(w, "r, next := input.Decode(%s)\nif next == %s || r != c {\n%s = -1\nbreak\n}\n%s = next\n}\n", end, end, end, end)
+		terminal(fmt.Sprintf("%s >= 0", end), end, strconv.Quote(l))
+	case "Range":
+		bounds := x.argument.([2]rune)
+		terminal(fmt.Sprintf("c, next := input.Decode(%s); next > %s && c >= %s && c <= %s", s, s, strconv.QuoteRune(bounds[0]), strconv.QuoteRune(bounds[1])), "next", "string(c)")
+	case "Any":
+		terminal(fmt.Sprintf("c, next := input.Decode(%s); next > %s", s, s), "next", "string(c)")
+	case "AnyOf":
+		cs := []string{}
+		for _, c := range x.argument.(string) {
+			cs = append(cs, "c == "+strconv.QuoteRune(c))
+		}
+		terminal(fmt.Sprintf("c, next := input.Decode(%s); next > %s && (%s)", s, s, strings.Join(cs, " || ")), "next", "string(c)")
+	case "Sequence":
+		c, n, cut, l := g.next("children"), g.next("next"), g.next("cut"), g.next("sequence")
+		fmt.Fprintf(w, "%s := []*seared.Result{}\n%s := %s\n%s := false\n%s:\nfor {\n", c, n, s, cut, l)
+		for _, o := range x.operands {
+			o := g.emit(w, o, n)
+			fmt.Fprintf(w, "%s = append(%s, %s)\n%s = %s || %s.Cut\n", c, c, o, cut, cut, o)
+			fmt.Fprintf(w, "if !%s.Success {\n%s.WithResults(%s...).WithCut(%s).WithThrown(%s.Thrown)\nbreak %s\n}\n", o, failure(o+".End"), c, cut, o, l)
+			fmt.Fprintf(w, "%s = %s.End\n", n, o)
+		}
+		fmt.Fprintf(w, "%s.WithResults(%s...).WithNodes(seared.ResultsNodes(%s)...).WithCut(%s)\nbreak\n}\n", success(n), c, c, cut)
+	case "Choice":
+		c, l := g.next("children"), g.next("choice")
+		if !g.predictive || x.dispatch == nil {
+			fmt.Fprintf(w, "%s := []*seared.Result{}\n%s:\nfor {\n", c, l)
+			for i, operand := range x.operands {
+				o := g.emit(w, operand, s)
+				fmt.Fprintf(w, "%s = append(%s, %s)\n", c, c, o)
+				fmt.Fprintf(w, "if %s.Success {\n%s.WithResults(%s...).WithNodes(%s.Nodes...)\nbreak %s\n}\n", o, success(o+".End"), c, o, l)
+				if i < len(x.operands)-1 {
+					fmt.Fprintf(w, "if %s.Cut || %s.Thrown != nil {\n%s.WithResults(%s...).WithCut(%s.Cut).WithThrown(%s.Thrown)\nbreak %s\n}\n", o, o, failure(o+".End"), c, o, o, l)
+				} else {
+					fmt.Fprintf(w, "%s.WithResults(%s...).WithCut(%s.Cut).WithThrown(%s.Thrown)\nbreak %s\n", failure(o+".End"), c, o, o, l)
+				}
+			}
+			fmt.Fprintf(w, "}\n")
+			break
+		}
+		// runs are the Skipped expressions standing for the alternatives skipped
+		// before each alternative, or after the last one, by the index of the
+		// first alternative skipped
+		runs := make([]map[int]Expression, len(x.operands)+1)
+		for _, as := range append([]*alternatives{x.dispatch.end}, x.dispatch.intervals...) {
+			from := 0
+			for i, skipped := range as.skipped {
+				to := len(x.operands)
+				if i < len(as.indexes) {
+					to = as.indexes[i]
+				}
+				if skipped != nil {
+					if runs[to] == nil {
+						runs[to] = map[int]Expression{}
+					}
+					runs[to][from] = skipped
+				}
+				from = to + 1
+			}
+		}
+		ch, nx, f, last := g.next("c"), g.next("next"), g.next("from"), g.next("r")
+		fmt.Fprintf(w, "%s, %s := input.Decode(%s)\n%s := []*seared.Result{}\n%s := 0\nvar %s *seared.Result\n%s:\nfor {\n", ch, nx, s, c, f, last, l)
+		skip := func(to int) {
+			if len(runs[to]) == 0 {
+				return
+			}
+			froms := []int{}
+			for from := range runs[to] {
+				froms = append(froms, from)
+			}
+			sort.Ints(froms)
+			fmt.Fprintf(w, "switch %s {\n", f)
+			for _, from := range froms {
+				fmt.Fprintf(w, "case %d:\n%s = seared.Failure(%s, input, %s, %s)\n%s = append(%s, %s)\n", from, last, g.name(runs[to][from]), s, s, c, c, last)
+			}
+			fmt.Fprintf(w, "}\n")
+		}
+		for i, operand := range x.operands {
+			if p := x.predictions[i]; !p.always {
+				fmt.Fprintf(w, "if %s {\n", matching(p.first, ch, nx, s))
+			} else {
+				fmt.Fprintf(w, "{\n")
+			}
+			skip(i)
+			o := g.emit(w, operand, s)
+			fmt.Fprintf(w, "%s = %s\n%s = append(%s, %s)\n%s = %d\n", last, o, c, c, o, f, i+1)
+			fmt.Fprintf(w, "if %s.Success {\n%s.WithResults(%s...).WithNodes(%s.Nodes...)\nbreak %s\n}\n", o, success(o+".End"), c, o, l)
+			fmt.Fprintf(w, "if %s.Cut || %s.Thrown != nil {\n%s.WithResults(%s...).WithCut(%s.Cut).WithThrown(%s.Thrown)\nbreak %s\n}\n}\n", o, o, failure(o+".End"), c, o, o, l)
+		}
+		skip(len(x.operands))
+		fmt.Fprintf(w, "%s.WithResults(%s...)\nbreak %s\n}\n", failure(last+".End"), c, l)
+	case "ZeroOrMore":
+		c, n := g.next("children"), g.next("next")
+		fmt.Fprintf(w, "%s := []*seared.Result{}\n%s := %s\nfor {\n", c, n, s)
+		o := g.emit(w, x.operands[0], n)
+		fmt.Fprintf(w, "%s = append(%s, %s)\nif !%s.Success {\n", c, c, o, o)
+		fmt.Fprintf(w, "if %s.Cut || %s.Thrown != nil {\n%s.WithResults(%s...).WithCut(%s.Cut).WithThrown(%s.Thrown)\nbreak\n}\n", o, o, failure(o+".End"), c, o, o)
+		fmt.Fprintf(w, "if len(%s) > 1 {\n%s = %s[0 : len(%s)-1]\n}\n", c, c, c, c)
+		fmt.Fprintf(w, "%s.WithResults(%s...).WithNodes(seared.ResultsNodes(%s)...)\nbreak\n}\n", success(n), c, c)
+		fmt.Fprintf(w, "%s = %s.End\n}\n", n, o)
+	case "OneOrMore":
+		c, n, m := g.next("children"), g.next("next"), g.next("matched")
+		fmt.Fprintf(w, "%s := []*seared.Result{}\n%s := %s\n%s := false\nfor {\n", c, n, s, m)
+		o := g.emit(w, x.operands[0], n)
+		fmt.Fprintf(w, "%s = append(%s, %s)\nif !%s.Success {\n", c, c, o, o)
+		fmt.Fprintf(w, "if %s && !%s.Cut && %s.Thrown == nil {\n%s = %s[0 : len(%s)-1]\n%s.WithResults(%s...).WithNodes(seared.ResultsNodes(%s)...)\nbreak\n}\n", m, o, o, c, c, c, success(n), c, c)
+		fmt.Fprintf(w, "%s.WithResults(%s...).WithCut(%s.Cut).WithThrown(%s.Thrown)\nbreak\n}\n", failure(o+".End"), c, o, o)
+		fmt.Fprintf(w, "%s = %s.End\n%s = true\n}\n", n, o, m)
+	case "Optional":
+		o := g.emit(w, x.operands[0], s)
+		fmt.Fprintf(w, "if !%s.Success && (%s.Cut || %s.Thrown != nil) {\n%s.WithResults(%s).WithCut(%s.Cut).WithThrown(%s.Thrown)\n} else {\n%s.WithResults(%s).WithNodes(%s.Nodes...)\n}\n", o, o, o, failure(o+".End"), o, o, o, success(o+".End"), o, o)
+	case "Test":
+		o := g.emit(w, x.operands[0], s)
+		fmt.Fprintf(w, "if %s.Success {\n%s.WithResults(%s)\n} else {\n%s.WithResults(%s).WithThrown(%s.Thrown)\n}\n", o, success(s), o, failure(o+".End"), o, o)
+	case "TestNot":
+		o := g.emit(w, x.operands[0], s)
+		fmt.Fprintf(w, "if %s.Success || %s.Thrown != nil {\n%s.WithResults(%s).WithThrown(%s.Thrown)\n} else {\n%s.WithResults(%s)\n}\n", o, o, failure(o+".End"), o, o, success(s), o)
+	case "Token":
+		o := g.emit(w, x.operands[0], s)
+		g.nodes = true
+		fmt.Fprintf(w, "if %s.Success {\n%s.WithResults(%s).WithCut(%s.Cut)\ntext := input.String(%s, %s.End)\n%s.WithNodes(node.NewTerminal(text).WithSpan(%s, %s.End))\n} else {\n%s.WithResults(%s).WithCut(%s.Cut).WithThrown(%s.Thrown)\n}\n", o, success(o+".End"), o, o, s, o, r, s, o, failure(o+".End"), o, o, o)
+	case "Cut":
+		fmt.Fprintf(w, "%s.WithCut(true)\n", success(s))
+	case "Throw":
+		t := x.argument.(throwArgument)
+		fmt.Fprintf(w, "%s.WithThrown(seared.NewLabeledError(%q, %q, input, %s))\n", failure(s), t.label, t.rule, s)
+	}
+	return r
+}
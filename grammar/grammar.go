@@ -0,0 +1,305 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package grammar models the grammars of seared parsers for tools like
+// analyzers, visualizers or exporters, as returned by Parser.Grammar.
+package grammar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Operator is the kind of a parsing expression
+type Operator int
+
+const (
+	// Reference applies a rule of the grammar
+	Reference Operator = iota
+	Empty
+	End
+	Any
+	Rune
+	Literal
+	Range
+	AnyOf
+	Sequence
+	Choice
+	ZeroOrMore
+	OneOrMore
+	Optional
+	Test
+	TestNot
+	Cut
+	Throw
+	Action
+	Token
+	// Custom is an expression not built by a Builder, like the ones of
+	// generated parsers, whose structure is unknown
+	Custom
+)
+
+var operators = []string{"Reference", "Empty", "End", "Any", "Rune", "Literal", "Range", "AnyOf", "Sequence",
+	"Choice", "ZeroOrMore", "OneOrMore", "Optional", "Test", "TestNot", "Cut", "Throw", "Action", "Token", "Custom"}
+
+func (o Operator) String() string {
+	if o >= 0 && int(o) < len(operators) {
+		return operators[o]
+	}
+	return "Operator(" + strconv.Itoa(int(o)) + ")"
+}
+
+// ParseOperator returns the operator named name, as returned by its String
+// method.
+func ParseOperator(name string) (Operator, bool) {
+	for i, s := range operators {
+		if s == name {
+			return Operator(i), true
+		}
+	}
+	return 0, false
+}
+
+// Expression is a parsing expression of a grammar
+type Expression struct {
+	Operator Operator
+	// Operands are the expressions this one is made of
+	Operands []*Expression
+	// Rule is the rule applied by Reference expressions
+	Rule *Rule
+	// Text is the text matched by Literal, the characters matched by AnyOf,
+	// the label raised by Throw and the name of Custom expressions
+	Text string
+	// Low and High are the characters matched by Rune, both being the same,
+	// and the bounds of the characters matched by Range
+	Low  rune
+	High rune
+	// Expectation describes the input expected by the expression in syntax
+	// errors
+	Expectation string
+}
+
+// Rule is a named rule of a grammar
+type Rule struct {
+	Name       string
+	Expression *Expression
+	// DropNode tells whether the rule produces neither nodes nor values
+	DropNode bool
+	// OmitNode tells whether the rule produces the nodes of its expression
+	// instead of its own
+	OmitNode bool
+	// Memoize tells whether the results of the rule may be memoized
+	Memoize bool
+	// Synchronization is the expression skipping input after syntax errors in
+	// recovery mode, if any
+	Synchronization *Expression
+}
+
+// Grammar is the grammar of a parser
+type Grammar struct {
+	// Name is the name of the parser
+	Name string
+	// Main is the expression the parser matches its input with
+	Main *Expression
+	// Rules are the rules reachable from the main, synchronization and
+	// recovery expressions in depth first order
+	Rules []*Rule
+	// Recoveries are the expressions recovering from the failures labeled by
+	// their keys
+	Recoveries map[string]*Expression
+}
+
+// Rule returns the rule named name, if any.
+func (g *Grammar) Rule(name string) *Rule {
+	for _, r := range g.Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Inspect calls f with e and, while f returns true, with its operands in depth
+// first order, without following the rules applied by references.
+func Inspect(e *Expression, f func(*Expression) bool) {
+	if e == nil || !f(e) {
+		return
+	}
+	for _, o := range e.Operands {
+		Inspect(o, f)
+	}
+}
+
+// String returns the grammar in PEG notation with one line per rule, starting
+// with the main expression, named after the grammar unless it applies a rule.
+func (g *Grammar) String() string {
+	var b strings.Builder
+	if g.Main != nil && g.Main.Operator != Reference {
+		fmt.Fprintf(&b, "%s <- %s\n", g.Name, g.Main)
+	}
+	for _, r := range g.Rules {
+		fmt.Fprintf(&b, "%s <- %s\n", r.Name, r.Expression)
+	}
+	return b.String()
+}
+
+// String returns e in PEG notation, extended with ^ for Cut, %{label} for
+// Throw, <e> for Token and {expectation} for Custom expressions. Action
+// expressions are written as their operand.
+func (e *Expression) String() string {
+	return e.format(0)
+}
+
+// Precedence levels of the PEG notation
+const (
+	choiceLevel = iota
+	sequenceLevel
+	prefixLevel
+	suffixLevel
+	primaryLevel
+)
+
+// level returns the precedence level of e
+func (e *Expression) level() int {
+	switch e.Operator {
+	case Choice:
+		if len(e.Operands) > 1 {
+			return choiceLevel
+		}
+	case Sequence:
+		if len(e.Operands) > 1 {
+			return sequenceLevel
+		}
+	case Test, TestNot, End:
+		return prefixLevel
+	case ZeroOrMore, OneOrMore, Optional:
+		return suffixLevel
+	}
+	if (e.Operator == Choice || e.Operator == Sequence || e.Operator == Action) && len(e.Operands) == 1 {
+		return e.Operands[0].level()
+	}
+	return primaryLevel
+}
+
+// format returns e in PEG notation, parenthesized when its precedence is lower
+// than level.
+func (e *Expression) format(level int) string {
+	if e == nil {
+		return "''"
+	}
+	if e.level() < level {
+		return "(" + e.format(0) + ")"
+	}
+	join := func(sep string, level int) string {
+		ss := []string{}
+		for _, o := range e.Operands {
+			ss = append(ss, o.format(level))
+		}
+		return strings.Join(ss, sep)
+	}
+	switch e.Operator {
+	case Reference:
+		if e.Rule != nil {
+			return e.Rule.Name
+		}
+	case Empty:
+		return "''"
+	case End:
+		return "!."
+	case Any:
+		return "."
+	case Rune:
+		return quote(string(e.Low))
+	case Literal:
+		return quote(e.Text)
+	case Range:
+		return "[" + escape(e.Low, `\[]-`) + "-" + escape(e.High, `\[]-`) + "]"
+	case AnyOf:
+		cs := []rune(e.Text)
+		s := ""
+		for i, c := range cs {
+			if i == len(cs)-1 {
+				s += escape(c, `\[]`)
+			} else {
+				s += escape(c, `\[]-`)
+			}
+		}
+		return "[" + s + "]"
+	case Sequence:
+		return join(" ", prefixLevel)
+	case Choice:
+		return join(" / ", sequenceLevel)
+	case ZeroOrMore:
+		return join("", primaryLevel) + "*"
+	case OneOrMore:
+		return join("", primaryLevel) + "+"
+	case Optional:
+		return join("", primaryLevel) + "?"
+	case Test:
+		return "&" + join("", suffixLevel)
+	case TestNot:
+		return "!" + join("", suffixLevel)
+	case Cut:
+		return "^"
+	case Throw:
+		return "%{" + e.Text + "}"
+	case Action:
+		return join("", level)
+	case Token:
+		return "<" + join("", choiceLevel) + ">"
+	}
+	return "{" + e.Expectation + "}"
+}
+
+// quote returns s as a PEG literal
+func quote(s string) string {
+	q := ""
+	for _, c := range s {
+		q += escape(c, `\'`)
+	}
+	return "'" + q + "'"
+}
+
+// escape returns c as written in PEG literals and classes, escaping it when
+// one of special.
+func escape(c rune, special string) string {
+	switch c {
+	case '\n':
+		return `\n`
+	case '\r':
+		return `\r`
+	case '\t':
+		return `\t`
+	}
+	if strings.ContainsRune(special, c) {
+		return `\` + string(c)
+	}
+	if c <= 0xff && !unicode.IsPrint(c) {
+		return fmt.Sprintf(`\%03o`, c)
+	}
+	return string(c)
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperator(t *testing.T) {
+	for o := Reference; o <= Custom; o++ {
+		p, ok := ParseOperator(o.String())
+		assert.True(t, ok)
+		assert.Equal(t, o, p)
+	}
+	assert.Equal(t, "Operator(99)", Operator(99).String())
+	_, ok := ParseOperator("Nothing")
+	assert.False(t, ok)
+}
+
+func TestInspect(t *testing.T) {
+	r := &Rule{Name: "A"}
+	r.Expression = &Expression{Operator: Sequence, Operands: []*Expression{
+		{Operator: Rune, Low: 'a', High: 'a'},
+		{Operator: Optional, Operands: []*Expression{{Operator: Reference, Rule: r}}},
+	}}
+	g := &Grammar{Name: "A", Main: &Expression{Operator: Reference, Rule: r}, Rules: []*Rule{r}}
+	assert.Equal(t, r, g.Rule("A"))
+	visited := []Operator{}
+	Inspect(r.Expression, func(e *Expression) bool {
+		visited = append(visited, e.Operator)
+		return e.Operator != Optional
+	})
+	assert.Equal(t, []Operator{Sequence, Rune, Optional}, visited)
+}
+
+func TestString(t *testing.T) {
+	number := &Rule{Name: "Number", Expression: &Expression{Operator: Token, Operands: []*Expression{
+		{Operator: OneOrMore, Operands: []*Expression{{Operator: Range, Low: '0', High: '9'}}},
+	}}}
+	list := &Rule{Name: "List"}
+	list.Expression = &Expression{Operator: Sequence, Operands: []*Expression{
+		{Operator: Reference, Rule: number},
+		{Operator: ZeroOrMore, Operands: []*Expression{{Operator: Sequence, Operands: []*Expression{
+			{Operator: AnyOf, Text: ",-"},
+			{Operator: Cut},
+			{Operator: Choice, Operands: []*Expression{{Operator: Reference, Rule: number}, {Operator: Throw, Text: "number"}}},
+		}}}},
+		{Operator: TestNot, Operands: []*Expression{{Operator: Optional, Operands: []*Expression{{Operator: Literal, Text: "it's\n"}}}}},
+		{Operator: Test, Operands: []*Expression{{Operator: Sequence, Operands: []*Expression{{Operator: Rune, Low: '\\'}, {Operator: Any}}}}},
+		{Operator: Action, Operands: []*Expression{{Operator: Range, Low: '-', High: ']'}}},
+		{Operator: Custom, Text: "custom", Expectation: "something"},
+		{Operator: End},
+	}}
+	g := &Grammar{Name: "List", Main: &Expression{Operator: Reference, Rule: list}, Rules: []*Rule{list, number}}
+	assert.Equal(t, `List <- Number ([,-] ^ (Number / %{number}))* !'it\'s\n'? &('\\' .) [\--\]] {something} !.
+Number <- <[0-9]+>
+`, g.String())
+	g = &Grammar{Name: "Main", Main: &Expression{Operator: ZeroOrMore, Operands: []*Expression{{Operator: End}}}}
+	assert.Equal(t, "Main <- (!.)*\n", g.String())
+}
@@ -28,9 +28,12 @@ package location
 import "fmt"
 
 type Location struct {
-	Line     int
-	Column   int
+	Line   int
+	Column int
+	// Position is the offset in runes from the start of input
 	Position int
+	// Offset is the offset in bytes from the start of the UTF-8 encoded input
+	Offset int
 }
 
 func (l Location) String() string {
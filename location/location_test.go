@@ -32,12 +32,12 @@ import (
 )
 
 func TestLocationString(t *testing.T) {
-	l := &Location{10, 30, 40}
+	l := &Location{Line: 10, Column: 30, Position: 40}
 	assert.EqualValues(t, "position 40 (line 10, column 30)", l.String())
 }
 
 func TestLocationStringShort(t *testing.T) {
-	l := &Location{10, 30, 40}
+	l := &Location{Line: 10, Column: 30, Position: 40}
 	assert.EqualValues(t, "40/10:30", l.ShortString())
 }
 
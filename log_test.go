@@ -42,3 +42,18 @@ func TestNewParser(t *testing.T) {
 	a.NotNil(p.log)
 	a.NotNil(p.main)
 }
+
+func TestNewNamedParser(t *testing.T) {
+	a := assert.New(t)
+	var letters Expression
+	p := NewNamedParser("Letters", func(b *Builder) Expression {
+		letters = b.NamedRule("Letters", func() Expression {
+			return b.OneOrMore(b.Range('a', 'z'))
+		})
+		a.Equal(letters, b.NamedRule("Letters", nil))
+		return letters
+	})
+	a.Equal("Letters", p.Name())
+	a.Equal("Letters", letters.Name())
+	a.Equal(`(Letters "a" "b")`, p.ParseString("ab").FormatNodeTree())
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package seared
+
+import (
+	"sort"
+
+	"github.com/lalloni/seared/grammar"
+)
+
+// Grammar returns a model of the grammar of p. The model is built anew on
+// every call and changing it does not affect p.
+func (p *Parser) Grammar() *grammar.Grammar {
+	m := &modeler{rules: map[*rule]*grammar.Rule{}, expressions: map[Expression]*grammar.Expression{}}
+	g := &grammar.Grammar{Name: p.name, Recoveries: map[string]*grammar.Expression{}}
+	g.Main = m.expression(p.main)
+	labels := []string{}
+	for label := range p.recoveries {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		g.Recoveries[label] = m.expression(p.recoveries[label])
+	}
+	g.Rules = m.order
+	return g
+}
+
+// modeler builds the models of the expressions of a grammar
+type modeler struct {
+	rules       map[*rule]*grammar.Rule
+	order       []*grammar.Rule
+	expressions map[Expression]*grammar.Expression
+}
+
+func (m *modeler) rule(r *rule) *grammar.Rule {
+	if model, ok := m.rules[r]; ok {
+		return model
+	}
+	model := &grammar.Rule{Name: r.Name(), DropNode: r.dropNode, OmitNode: r.omitNode, Memoize: r.memoize}
+	m.rules[r] = model
+	m.order = append(m.order, model)
+	model.Expression = m.expression(r.expression)
+	model.Synchronization = m.expression(r.sync)
+	return model
+}
+
+func (m *modeler) expression(e Expression) *grammar.Expression {
+	if e == nil {
+		return nil
+	}
+	if model, ok := m.expressions[e]; ok {
+		return model
+	}
+	model := &grammar.Expression{Expectation: e.Expectation()}
+	m.expressions[e] = model
+	switch x := e.(type) {
+	case *rule:
+		model.Operator = grammar.Reference
+		model.Rule = m.rule(x)
+		return model
+	case *expression:
+		op, ok := grammar.ParseOperator(x.name)
+		if !ok || x.parser == nil {
+			break
+		}
+		model.Operator = op
+		switch a := x.argument.(type) {
+		case rune:
+			model.Low, model.High = a, a
+		case [2]rune:
+			model.Low, model.High = a[0], a[1]
+		case string:
+			model.Text = a
+		case throwArgument:
+			model.Text = a.label
+		}
+		for _, o := range x.operands {
+			model.Operands = append(model.Operands, m.expression(o))
+		}
+		return model
+	}
+	model.Operator = grammar.Custom
+	model.Text = e.Name()
+	return model
+}
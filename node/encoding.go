@@ -0,0 +1,363 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package node
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var kinds = map[Kind]string{
+	Terminal:    "terminal",
+	NonTerminal: "nonterminal",
+	Error:       "error",
+	Trivia:      "trivia",
+}
+
+func (k Kind) String() string {
+	if s, ok := kinds[k]; ok {
+		return s
+	}
+	return "Kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+func (k Kind) MarshalText() ([]byte, error) {
+	if s, ok := kinds[k]; ok {
+		return []byte(s), nil
+	}
+	return nil, fmt.Errorf("node: cannot marshal kind %d", int(k))
+}
+
+func (k *Kind) UnmarshalText(text []byte) error {
+	for kind, s := range kinds {
+		if s == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("node: unknown kind %q", text)
+}
+
+// Parse returns the tree written in source with the syntax printed by Format
+// or FormatSpans.
+func Parse(source string) (*Node, error) {
+	s := &scanner{source: source}
+	n, err := s.node()
+	if err != nil {
+		return nil, err
+	}
+	if t := s.next(); t != "" {
+		return nil, s.errorf("unexpected %q after node", t)
+	}
+	return n, nil
+}
+
+func (s *scanner) node() (*Node, error) {
+	t := s.next()
+	switch {
+	case t == "":
+		return nil, s.errorf("unexpected end")
+	case t == "{":
+		return s.trivia()
+	case t[0] == '"':
+		v, err := strconv.Unquote(t)
+		if err != nil {
+			return nil, s.errorf("invalid string %s", t)
+		}
+		n := NewTerminal(v)
+		save := s.pos
+		if t := s.next(); strings.HasPrefix(t, "@") {
+			return n, s.span(n, t)
+		}
+		s.pos = save
+		return n, nil
+	case t != "(":
+		return nil, s.errorf("unexpected %q", t)
+	}
+	label := s.next()
+	if label == "" || label == "(" || label == ")" || label[0] == '"' {
+		return nil, s.errorf("missing label")
+	}
+	span := ""
+	if i := strings.LastIndexByte(label, '@'); i >= 0 {
+		label, span = label[:i], label[i:]
+	}
+	var n *Node
+	if label[0] == '!' || label[0] == '~' {
+		t := s.next()
+		v, err := strconv.Unquote(t)
+		if err != nil {
+			return nil, s.errorf("node %s needs a value", label)
+		}
+		if t := s.next(); t != ")" {
+			return nil, s.errorf("expected ) instead of %q", t)
+		}
+		if label[0] == '!' {
+			n = NewError(label[1:], v)
+		} else {
+			n = NewTrivia(label[1:], v)
+		}
+	} else {
+		n = NewNonTerminal(label, []*Node{})
+		for {
+			save := s.pos
+			if s.next() == ")" {
+				break
+			}
+			s.pos = save
+			c, err := s.node()
+			if err != nil {
+				return nil, err
+			}
+			n.Children = append(n.Children, c)
+		}
+	}
+	if span != "" {
+		return n, s.span(n, span)
+	}
+	return n, nil
+}
+
+// trivia reads a terminal or error node wrapped in braces along with the
+// trivia attached to it, after the opening brace.
+func (s *scanner) trivia() (*Node, error) {
+	var n *Node
+	leading, trailing := []*Node{}, []*Node{}
+	for {
+		save := s.pos
+		if s.next() == "}" {
+			break
+		}
+		s.pos = save
+		c, err := s.node()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case c.Kind == Trivia && n == nil:
+			leading = append(leading, c)
+		case c.Kind == Trivia:
+			trailing = append(trailing, c)
+		case n == nil && (c.Kind == Terminal || c.Kind == Error) && len(c.Leading) == 0 && len(c.Trailing) == 0:
+			n = c
+		default:
+			return nil, s.errorf("unexpected %s node among trivia", c.Kind)
+		}
+	}
+	if n == nil {
+		return nil, s.errorf("missing node among trivia")
+	}
+	if len(leading) > 0 {
+		n.Leading = leading
+	}
+	if len(trailing) > 0 {
+		n.Trailing = trailing
+	}
+	return n, nil
+}
+
+// span sets the span of n from a @start:end suffix
+func (s *scanner) span(n *Node, suffix string) error {
+	if _, err := fmt.Sscanf(suffix, "@%d:%d", &n.Start, &n.End); err != nil {
+		return s.errorf("invalid span %q", suffix)
+	}
+	return nil
+}
+
+type jsonNode struct {
+	Kind     Kind    `json:"kind"`
+	Label    string  `json:"label,omitempty"`
+	Value    string  `json:"value,omitempty"`
+	Span     *[2]int `json:"span,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+	Leading  []*Node `json:"leading,omitempty"`
+	Trailing []*Node `json:"trailing,omitempty"`
+}
+
+// MarshalJSON encodes n as an object with its kind, label, value, span as a
+// [start, end] array unless both are 0, children and trivia.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	j := jsonNode{Kind: n.Kind, Label: n.Label, Value: n.Value, Children: n.Children, Leading: n.Leading, Trailing: n.Trailing}
+	if n.Start != 0 || n.End != 0 {
+		j.Span = &[2]int{n.Start, n.End}
+	}
+	return json.Marshal(j)
+}
+
+func (n *Node) UnmarshalJSON(data []byte) error {
+	j := jsonNode{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*n = Node{Kind: j.Kind, Label: j.Label, Value: j.Value, Children: j.Children, Leading: j.Leading, Trailing: j.Trailing}
+	if n.Kind == NonTerminal && n.Children == nil {
+		n.Children = []*Node{}
+	}
+	if j.Span != nil {
+		n.Start, n.End = j.Span[0], j.Span[1]
+	}
+	return nil
+}
+
+// MarshalXML encodes n as an element named after its kind with label, start
+// and end attributes, containing its children or its value, preceded and
+// followed by its trivia wrapped in leading and trailing elements.
+func (n *Node) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	name, err := n.Kind.MarshalText()
+	if err != nil {
+		return err
+	}
+	start = xml.StartElement{Name: xml.Name{Local: string(name)}}
+	if n.Label != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "label"}, Value: n.Label})
+	}
+	if n.Start != 0 || n.End != 0 {
+		start.Attr = append(start.Attr,
+			xml.Attr{Name: xml.Name{Local: "start"}, Value: strconv.Itoa(n.Start)},
+			xml.Attr{Name: xml.Name{Local: "end"}, Value: strconv.Itoa(n.End)})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeTrivia(e, "leading", n.Leading); err != nil {
+		return err
+	}
+	if n.Kind == NonTerminal {
+		for _, c := range n.Children {
+			if err := e.Encode(c); err != nil {
+				return err
+			}
+		}
+	} else if n.Value != "" {
+		if err := e.EncodeToken(xml.CharData(n.Value)); err != nil {
+			return err
+		}
+	}
+	if err := encodeTrivia(e, "trailing", n.Trailing); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+func encodeTrivia(e *xml.Encoder, name string, ns []*Node) error {
+	if len(ns) == 0 {
+		return nil
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, t := range ns {
+		if err := e.Encode(t); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func decodeTrivia(d *xml.Decoder) ([]*Node, error) {
+	var ns []*Node
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			n := &Node{}
+			if err := n.UnmarshalXML(d, t); err != nil {
+				return nil, err
+			}
+			ns = append(ns, n)
+		case xml.EndElement:
+			return ns, nil
+		}
+	}
+}
+
+func (n *Node) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*n = Node{}
+	if err := n.Kind.UnmarshalText([]byte(start.Name.Local)); err != nil {
+		return err
+	}
+	if n.Kind == NonTerminal {
+		n.Children = []*Node{}
+	}
+	for _, a := range start.Attr {
+		var err error
+		switch a.Name.Local {
+		case "label":
+			n.Label = a.Value
+		case "start":
+			n.Start, err = strconv.Atoi(a.Value)
+		case "end":
+			n.End, err = strconv.Atoi(a.Value)
+		}
+		if err != nil {
+			return fmt.Errorf("node: invalid %s attribute %q", a.Name.Local, a.Value)
+		}
+	}
+	value := strings.Builder{}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.CharData:
+			value.Write(t)
+		case xml.StartElement:
+			if t.Name.Local == "leading" || t.Name.Local == "trailing" {
+				ns, err := decodeTrivia(d)
+				if err != nil {
+					return err
+				}
+				if t.Name.Local == "leading" {
+					n.Leading = ns
+				} else {
+					n.Trailing = ns
+				}
+				continue
+			}
+			if n.Kind != NonTerminal {
+				return fmt.Errorf("node: unexpected element %s in %s node", t.Name.Local, n.Kind)
+			}
+			c := &Node{}
+			if err := c.UnmarshalXML(d, t); err != nil {
+				return err
+			}
+			n.Children = append(n.Children, c)
+		case xml.EndElement:
+			if n.Kind != NonTerminal {
+				n.Value = value.String()
+			}
+			return nil
+		}
+	}
+}
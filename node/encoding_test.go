@@ -0,0 +1,110 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package node
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// spanned is a tree with spans and values needing escapes
+func spanned() *Node {
+	return NewNonTerminal("Assign", []*Node{
+		NewNonTerminal("Name", []*Node{NewTerminal("a").WithSpan(0, 1)}).WithSpan(0, 1),
+		NewTerminal("=").WithSpan(1, 2),
+		NewNonTerminal("String", []*Node{NewTerminal("\"x\\y\"\r\n").WithSpan(2, 9)}).WithSpan(2, 9),
+		NewError("Assign", " ;").WithSpan(9, 11),
+		NewNonTerminal("Empty", []*Node{}).WithSpan(11, 11),
+	}).WithSpan(0, 11)
+}
+
+func TestFormat(t *testing.T) {
+	n := spanned()
+	assert.Equal(t, `(Assign (Name "a") "=" (String "\"x\\y\"\r\n") (!Assign " ;") (Empty ))`, n.Format())
+	assert.Equal(t, `(Assign@0:11 (Name@0:1 "a"@0:1) "="@1:2 (String@2:9 "\"x\\y\"\r\n"@2:9) (!Assign@9:11 " ;") (Empty@11:11 ))`, n.FormatSpans())
+}
+
+func TestParse(t *testing.T) {
+	n := spanned()
+	p, err := Parse(n.FormatSpans())
+	if assert.NoError(t, err) {
+		assert.Equal(t, n, p)
+	}
+	p, err = Parse(n.Format())
+	if assert.NoError(t, err) {
+		assert.Equal(t, n.Format(), p.Format())
+		assert.Equal(t, 0, p.End)
+	}
+	for _, source := range []string{``, `(`, `(A "a"`, `(!E)`, `(A) "b"`, `?x`, `"a"@x`, `(A@1 "a")`} {
+		_, err := Parse(source)
+		assert.Error(t, err, source)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	n := spanned()
+	data, err := json.Marshal(n)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, string(data), `{"kind":"terminal","value":"=","span":[1,2]}`)
+	assert.Contains(t, string(data), `{"kind":"error","label":"Assign","value":" ;","span":[9,11]}`)
+	p := &Node{}
+	if assert.NoError(t, json.Unmarshal(data, p)) {
+		assert.Equal(t, n, p)
+	}
+	data, err = json.Marshal(NewTerminal("x"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"kind":"terminal","value":"x"}`, string(data))
+	}
+	assert.Error(t, json.Unmarshal([]byte(`{"kind":"leaf"}`), p))
+}
+
+func TestXML(t *testing.T) {
+	n := spanned()
+	data, err := xml.Marshal(n)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, string(data), `<terminal start="1" end="2">=</terminal>`)
+	assert.Contains(t, string(data), `<error label="Assign" start="9" end="11"> ;</error>`)
+	p := &Node{}
+	if assert.NoError(t, xml.Unmarshal(data, p)) {
+		assert.Equal(t, n, p)
+	}
+	data, err = xml.MarshalIndent(n, "", "  ")
+	if assert.NoError(t, err) {
+		p := &Node{}
+		if assert.NoError(t, xml.Unmarshal(data, p)) {
+			assert.Equal(t, n, p)
+		}
+	}
+	assert.Error(t, xml.Unmarshal([]byte(`<terminal><terminal/></terminal>`), p))
+	assert.Error(t, xml.Unmarshal([]byte(`<leaf/>`), p))
+}
@@ -27,7 +27,11 @@ package node
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/lalloni/seared/buffer"
+	"github.com/lalloni/seared/location"
 )
 
 type Kind int
@@ -35,6 +39,11 @@ type Kind int
 const (
 	Terminal Kind = iota
 	NonTerminal
+	// Error nodes stand for input skipped while recovering from syntax errors
+	Error
+	// Trivia nodes stand for input matched by rules dropping their nodes when
+	// parsing in concrete mode
+	Trivia
 )
 
 type Node struct {
@@ -42,6 +51,14 @@ type Node struct {
 	Label    string
 	Children []*Node
 	Value    string
+	// Start and End are the positions in the input buffer of the text the
+	// node was produced from
+	Start int
+	End   int
+	// Leading and Trailing are the trivia nodes attached to a terminal or
+	// error node standing for the input found before and after it
+	Leading  []*Node
+	Trailing []*Node
 }
 
 func NewTerminal(value string) *Node {
@@ -59,19 +76,87 @@ func NewNonTerminal(label string, children []*Node) *Node {
 	}
 }
 
+// NewError returns a node standing for the input value skipped while
+// recovering from a syntax error in the rule named label.
+func NewError(label string, value string) *Node {
+	return &Node{
+		Kind:  Error,
+		Label: label,
+		Value: value,
+	}
+}
+
+// NewTrivia returns a node standing for the input value matched by the rule
+// named label when parsing in concrete mode.
+func NewTrivia(label string, value string) *Node {
+	return &Node{
+		Kind:  Trivia,
+		Label: label,
+		Value: value,
+	}
+}
+
+// WithSpan sets the input positions of n from start to end.
+func (n *Node) WithSpan(start, end int) *Node {
+	n.Start = start
+	n.End = end
+	return n
+}
+
+// Span returns the locations of the start and end of n in input, which must be
+// the buffer n was parsed from.
+func (n *Node) Span(input buffer.Buffer) (start, end location.Location) {
+	return input.Location(n.Start), input.Location(n.End)
+}
+
+// Format returns n as an S-expression: (Label children...) for non-terminals,
+// "value" for terminals, (!Label "value") for error nodes and (~Label "value")
+// for trivia nodes. Terminal and error nodes having trivia attached are
+// wrapped in braces along with it, as in {(~Space " ") "value"}, where the
+// trivia before the node is the Leading one and after it the Trailing one.
+// Parse reads it back.
 func (n *Node) Format() string {
+	return n.format(false)
+}
+
+// FormatSpans is like Format but appends @start:end to the labels of
+// non-terminals and error nodes and to the values of terminals.
+func (n *Node) FormatSpans() string {
+	return n.format(true)
+}
+
+func (n *Node) format(spans bool) string {
+	span := ""
+	if spans {
+		span = fmt.Sprintf("@%d:%d", n.Start, n.End)
+	}
 	var s string
 	switch n.Kind {
 	case Terminal:
-		s = "\"" + n.Value + "\""
+		s = strconv.Quote(n.Value) + span
 	case NonTerminal:
 		ss := []string{}
 		for _, child := range n.Children {
-			ss = append(ss, child.Format())
+			ss = append(ss, child.format(spans))
 		}
-		s = "(" + n.Label + " " + strings.Join(ss, " ") + ")"
+		s = "(" + n.Label + span + " " + strings.Join(ss, " ") + ")"
+	case Error:
+		s = "(!" + n.Label + span + " " + strconv.Quote(n.Value) + ")"
+	case Trivia:
+		s = "(~" + n.Label + span + " " + strconv.Quote(n.Value) + ")"
 	default:
 		s = fmt.Sprintf("(kind %v node)", n.Kind)
 	}
+	if len(n.Leading) > 0 || len(n.Trailing) > 0 {
+		ss := []string{}
+		for _, t := range n.Leading {
+			ss = append(ss, t.format(spans))
+		}
+		ss = append(ss, s)
+		for _, t := range n.Trailing {
+			ss = append(ss, t.format(spans))
+		}
+		s = "{" + strings.Join(ss, " ") + "}"
+	}
 	return s
 }
@@ -0,0 +1,413 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Pattern is a parse tree pattern written with the syntax printed by Format:
+// (Label children...) for non-terminals, "value" for terminals and
+// (!Label "value") for error nodes, where ?name binds any node, ?name... binds
+// any number of sibling nodes, _ is any node and a _ label is any label.
+//
+// Patterns are also used as templates building nodes with the ones bound.
+type Pattern struct {
+	kind     patternKind
+	label    string
+	value    string
+	name     string
+	children []*Pattern
+}
+
+type patternKind int
+
+const (
+	patternNode patternKind = iota
+	patternTerminal
+	patternError
+	patternVariable
+	patternSequence
+	patternAny
+)
+
+// Bindings are the nodes bound to the variables of a pattern by name
+type Bindings map[string][]*Node
+
+// ParsePattern returns the pattern written in source.
+func ParsePattern(source string) (*Pattern, error) {
+	s := &scanner{source: source}
+	p, err := s.pattern()
+	if err != nil {
+		return nil, err
+	}
+	if t := s.next(); t != "" {
+		return nil, s.errorf("unexpected %q after pattern", t)
+	}
+	if p.kind == patternSequence {
+		return nil, s.errorf("sequence variable ?%s... outside of a node", p.name)
+	}
+	return p, nil
+}
+
+// MustPattern is like ParsePattern but panics if the pattern can not be
+// parsed.
+func MustPattern(source string) *Pattern {
+	p, err := ParsePattern(source)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *Pattern) String() string {
+	switch p.kind {
+	case patternTerminal:
+		return strconv.Quote(p.value)
+	case patternError:
+		return "(!" + p.label + " " + strconv.Quote(p.value) + ")"
+	case patternVariable:
+		return "?" + p.name
+	case patternSequence:
+		return "?" + p.name + "..."
+	case patternAny:
+		return "_"
+	}
+	ss := []string{p.label}
+	for _, c := range p.children {
+		ss = append(ss, c.String())
+	}
+	return "(" + strings.Join(ss, " ") + ")"
+}
+
+// Match tells whether n matches p, returning the nodes bound to its
+// variables. Sequence variables bind as few nodes as possible.
+func (p *Pattern) Match(n *Node) (Bindings, bool) {
+	b := Bindings{}
+	if !p.match(n, b) {
+		return nil, false
+	}
+	return b, true
+}
+
+func (p *Pattern) match(n *Node, b Bindings) bool {
+	switch p.kind {
+	case patternAny:
+		return true
+	case patternVariable:
+		return b.bind(p.name, []*Node{n})
+	case patternTerminal:
+		return n.Kind == Terminal && n.Value == p.value
+	case patternError:
+		return n.Kind == Error && (p.label == "_" || n.Label == p.label) && n.Value == p.value
+	}
+	if n.Kind != NonTerminal || (p.label != "_" && n.Label != p.label) {
+		return false
+	}
+	return matchChildren(p.children, n.Children, b)
+}
+
+func matchChildren(ps []*Pattern, ns []*Node, b Bindings) bool {
+	if len(ps) == 0 {
+		return len(ns) == 0
+	}
+	p := ps[0]
+	if p.kind != patternSequence {
+		if len(ns) == 0 {
+			return false
+		}
+		saved := b.copy()
+		if p.match(ns[0], b) && matchChildren(ps[1:], ns[1:], b) {
+			return true
+		}
+		b.restore(saved)
+		return false
+	}
+	for i := 0; i <= len(ns); i++ {
+		saved := b.copy()
+		if b.bind(p.name, ns[:i]) && matchChildren(ps[1:], ns[i:], b) {
+			return true
+		}
+		b.restore(saved)
+	}
+	return false
+}
+
+// bind binds name to ns unless it is bound to different nodes
+func (b Bindings) bind(name string, ns []*Node) bool {
+	if bound, ok := b[name]; ok {
+		if len(bound) != len(ns) {
+			return false
+		}
+		for i := range ns {
+			if !equal(bound[i], ns[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	b[name] = ns
+	return true
+}
+
+func (b Bindings) copy() Bindings {
+	c := Bindings{}
+	for k, v := range b {
+		c[k] = v
+	}
+	return c
+}
+
+func (b Bindings) restore(saved Bindings) {
+	for k := range b {
+		if _, ok := saved[k]; !ok {
+			delete(b, k)
+		}
+	}
+}
+
+// equal tells whether the trees a and b are equal, regardless of their spans
+func equal(a, b *Node) bool {
+	if a.Kind != b.Kind || a.Label != b.Label || a.Value != b.Value || len(a.Children) != len(b.Children) {
+		return false
+	}
+	for i := range a.Children {
+		if !equal(a.Children[i], b.Children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Build returns the nodes built from the template p with the nodes bound in b.
+// The nodes built by p get the span given.
+func (p *Pattern) Build(b Bindings, start, end int) []*Node {
+	switch p.kind {
+	case patternVariable, patternSequence:
+		return b[p.name]
+	case patternTerminal:
+		return []*Node{NewTerminal(p.value).WithSpan(start, end)}
+	case patternError:
+		return []*Node{NewError(p.label, p.value).WithSpan(start, end)}
+	case patternAny:
+		return nil
+	}
+	children := []*Node{}
+	for _, c := range p.children {
+		children = append(children, c.Build(b, start, end)...)
+	}
+	return []*Node{NewNonTerminal(p.label, children).WithSpan(start, end)}
+}
+
+// RewriteRule replaces the nodes matching its pattern by the node built by
+// its template.
+type RewriteRule struct {
+	Pattern  *Pattern
+	Template *Pattern
+}
+
+// NewRewriteRule returns the rule rewriting the nodes matching the pattern
+// written in pattern into the node built by the template written in template,
+// which may only use the variables of the pattern.
+func NewRewriteRule(pattern, template string) (*RewriteRule, error) {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ParsePattern(template)
+	if err != nil {
+		return nil, err
+	}
+	vs := p.variables(map[string]bool{})
+	for v := range t.variables(map[string]bool{}) {
+		if !vs[v] {
+			return nil, fmt.Errorf("node: template %s uses variable ?%s not bound by pattern %s", t, v, p)
+		}
+	}
+	if t.kind == patternAny {
+		return nil, fmt.Errorf("node: template %s builds no node", t)
+	}
+	return &RewriteRule{Pattern: p, Template: t}, nil
+}
+
+// MustRewriteRule is like NewRewriteRule but panics on error.
+func MustRewriteRule(pattern, template string) *RewriteRule {
+	r, err := NewRewriteRule(pattern, template)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (p *Pattern) variables(vs map[string]bool) map[string]bool {
+	if p.kind == patternVariable || p.kind == patternSequence {
+		vs[p.name] = true
+	}
+	for _, c := range p.children {
+		c.variables(vs)
+	}
+	return vs
+}
+
+// Rewrite returns the tree resulting from repeatedly replacing the nodes of
+// tree matching the pattern of the first of rules matching them, from the
+// leaves up, until no rule changes any node. The nodes of tree are not
+// modified. Rules must not rewrite nodes forever into different ones.
+func Rewrite(tree *Node, rules ...*RewriteRule) *Node {
+	for {
+		n, changed := rewrite(tree, rules)
+		if !changed {
+			return n
+		}
+		tree = n
+	}
+}
+
+func rewrite(n *Node, rules []*RewriteRule) (*Node, bool) {
+	changed := false
+	if len(n.Children) > 0 {
+		children := make([]*Node, len(n.Children))
+		for i, c := range n.Children {
+			var ch bool
+			children[i], ch = rewrite(c, rules)
+			changed = changed || ch
+		}
+		if changed {
+			c := *n
+			c.Children = children
+			n = &c
+		}
+	}
+	for _, r := range rules {
+		if b, ok := r.Pattern.Match(n); ok {
+			if ns := r.Template.Build(b, n.Start, n.End); len(ns) == 1 {
+				if equal(ns[0], n) {
+					return n, changed
+				}
+				return ns[0], true
+			}
+		}
+	}
+	return n, changed
+}
+
+type scanner struct {
+	source string
+	pos    int
+}
+
+func (s *scanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("node: cannot parse %q at offset %d: %s", s.source, s.pos, fmt.Sprintf(format, args...))
+}
+
+// next returns the next token: a parenthesis, a brace, a quoted string or a
+// word
+func (s *scanner) next() string {
+	for s.pos < len(s.source) && unicode.IsSpace(rune(s.source[s.pos])) {
+		s.pos++
+	}
+	if s.pos >= len(s.source) {
+		return ""
+	}
+	start := s.pos
+	switch s.source[s.pos] {
+	case '(', ')', '{', '}':
+		s.pos++
+	case '"':
+		s.pos++
+		for s.pos < len(s.source) && s.source[s.pos] != '"' {
+			if s.source[s.pos] == '\\' {
+				s.pos++
+			}
+			s.pos++
+		}
+		s.pos++
+	default:
+		for s.pos < len(s.source) && !strings.ContainsRune("(){}\" \t\r\n", rune(s.source[s.pos])) {
+			s.pos++
+		}
+	}
+	if s.pos > len(s.source) {
+		s.pos = len(s.source)
+	}
+	return s.source[start:s.pos]
+}
+
+func (s *scanner) pattern() (*Pattern, error) {
+	t := s.next()
+	switch {
+	case t == "":
+		return nil, s.errorf("unexpected end")
+	case t == "_":
+		return &Pattern{kind: patternAny}, nil
+	case t[0] == '"':
+		v, err := strconv.Unquote(t)
+		if err != nil {
+			return nil, s.errorf("invalid string %s", t)
+		}
+		return &Pattern{kind: patternTerminal, value: v}, nil
+	case t[0] == '?':
+		if name := strings.TrimSuffix(t[1:], "..."); name != t[1:] {
+			return &Pattern{kind: patternSequence, name: name}, nil
+		}
+		return &Pattern{kind: patternVariable, name: t[1:]}, nil
+	case t != "(":
+		return nil, s.errorf("unexpected %q", t)
+	}
+	label := s.next()
+	if label == "" || label == "(" || label == ")" || label[0] == '"' {
+		return nil, s.errorf("missing label")
+	}
+	if label[0] == '!' {
+		v, err := s.pattern()
+		if err != nil {
+			return nil, err
+		}
+		if v.kind != patternTerminal {
+			return nil, s.errorf("error node %s needs a value", label)
+		}
+		if t := s.next(); t != ")" {
+			return nil, s.errorf("expected ) instead of %q", t)
+		}
+		return &Pattern{kind: patternError, label: label[1:], value: v.value}, nil
+	}
+	p := &Pattern{kind: patternNode, label: label}
+	for {
+		save := s.pos
+		if s.next() == ")" {
+			return p, nil
+		}
+		s.pos = save
+		c, err := s.pattern()
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, c)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flat is the tree of "1+2-3" as parsed by a ZeroOrMore based sum rule
+func flat() *Node {
+	return NewNonTerminal("Sum", []*Node{
+		NewNonTerminal("Number", []*Node{NewTerminal("1")}),
+		NewTerminal("+"),
+		NewNonTerminal("Number", []*Node{NewTerminal("2")}),
+		NewTerminal("-"),
+		NewNonTerminal("Number", []*Node{NewTerminal("3")}),
+	})
+}
+
+func TestParsePattern(t *testing.T) {
+	for _, source := range []string{
+		`(Sum ?l "+" ?r ?rest...)`,
+		`(_ _ (!Error "x"))`,
+		`"a \"b\""`,
+		`?x`,
+	} {
+		p, err := ParsePattern(source)
+		if assert.NoError(t, err, source) {
+			assert.Equal(t, source, p.String())
+		}
+	}
+	for _, source := range []string{``, `(`, `()`, `(Sum "a"`, `?x...`, `(!Error ?x)`, `(Sum) x`, `)`} {
+		_, err := ParsePattern(source)
+		assert.Error(t, err, source)
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	b, ok := MustPattern(`(Sum ?l "+" ?r ?rest...)`).Match(flat())
+	if assert.True(t, ok) {
+		assert.Equal(t, `(Number "1")`, b["l"][0].Format())
+		assert.Equal(t, `(Number "2")`, b["r"][0].Format())
+		assert.Len(t, b["rest"], 2)
+	}
+	_, ok = MustPattern(`(Sum ?l "-" ?rest...)`).Match(flat())
+	assert.False(t, ok)
+	b, ok = MustPattern(`(Sum ?a... "-" ?b...)`).Match(flat())
+	if assert.True(t, ok) {
+		assert.Len(t, b["a"], 3)
+		assert.Len(t, b["b"], 1)
+	}
+	same := NewNonTerminal("Pair", []*Node{NewTerminal("a"), NewTerminal("a")})
+	_, ok = MustPattern(`(Pair ?x ?x)`).Match(same)
+	assert.True(t, ok)
+	_, ok = MustPattern(`(Pair ?x ?x)`).Match(NewNonTerminal("Pair", []*Node{NewTerminal("a"), NewTerminal("b")}))
+	assert.False(t, ok)
+}
+
+func TestRewrite(t *testing.T) {
+	tree := flat().WithSpan(0, 5)
+	result := Rewrite(tree,
+		MustRewriteRule(`(Sum ?l "+" ?r ?rest...)`, `(Sum (Add ?l ?r) ?rest...)`),
+		MustRewriteRule(`(Sum ?l "-" ?r ?rest...)`, `(Sum (Sub ?l ?r) ?rest...)`),
+		MustRewriteRule(`(Sum ?x)`, `?x`),
+		MustRewriteRule(`(Number ?x)`, `?x`),
+	)
+	assert.Equal(t, `(Sub (Add "1" "2") "3")`, result.Format())
+	assert.Equal(t, 0, result.Start)
+	assert.Equal(t, 5, result.End)
+	assert.Equal(t, flat().Format(), tree.Format())
+}
+
+func TestRewriteIdentity(t *testing.T) {
+	tree := flat().WithSpan(0, 5)
+	result := Rewrite(tree,
+		MustRewriteRule(`(Number ?x)`, `(Number ?x)`),
+		MustRewriteRule(`(Sum ?x...)`, `(Sum ?x...)`),
+	)
+	assert.Equal(t, flat().Format(), result.Format())
+	assert.Same(t, tree, result)
+}
+
+func TestNewRewriteRule(t *testing.T) {
+	_, err := NewRewriteRule(`(Sum ?x)`, `(Sum ?y)`)
+	assert.Error(t, err)
+	_, err = NewRewriteRule(`(Sum ?x)`, `_`)
+	assert.Error(t, err)
+	_, err = NewRewriteRule(`(Sum`, `?x`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package node
+
+import "strings"
+
+// AttachTrivia moves the trivia nodes found in the trees ns to the Leading
+// trivia of the terminal or error node following them or, when none follows,
+// to the Trailing trivia of the last one, returning ns without them. Trivia
+// nodes are left in place when the trees have no terminal or error nodes.
+func AttachTrivia(ns []*Node) []*Node {
+	var pending []*Node
+	var last *Node
+	var attach func([]*Node) []*Node
+	attach = func(ns []*Node) []*Node {
+		kept := make([]*Node, 0, len(ns))
+		for _, n := range ns {
+			switch n.Kind {
+			case Trivia:
+				pending = append(pending, n)
+				continue
+			case NonTerminal:
+				n.Children = attach(n.Children)
+			default:
+				n.Leading = append(n.Leading, pending...)
+				pending = nil
+				last = n
+			}
+			kept = append(kept, n)
+		}
+		return kept
+	}
+	if !hasLeaves(ns) {
+		return ns
+	}
+	ns = attach(ns)
+	if last != nil {
+		last.Trailing = append(last.Trailing, pending...)
+	}
+	return ns
+}
+
+func hasLeaves(ns []*Node) bool {
+	for _, n := range ns {
+		if n.Kind == Terminal || n.Kind == Error || n.Kind == NonTerminal && hasLeaves(n.Children) {
+			return true
+		}
+	}
+	return false
+}
+
+// Source returns the text n stands for including its trivia, which is the
+// input matched when parsing in concrete mode.
+func (n *Node) Source() string {
+	var b strings.Builder
+	Inspect(n, func(d *Node) bool {
+		if d == nil || d.Kind == NonTerminal {
+			return true
+		}
+		for _, t := range d.Leading {
+			b.WriteString(t.Value)
+		}
+		b.WriteString(d.Value)
+		for _, t := range d.Trailing {
+			b.WriteString(t.Value)
+		}
+		return true
+	})
+	return b.String()
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package node
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachTrivia(t *testing.T) {
+	ns := AttachTrivia([]*Node{
+		NewTrivia("Space", " "),
+		NewNonTerminal("Sum", []*Node{
+			NewTerminal("1"),
+			NewTrivia("Space", " "),
+			NewTrivia("Comment", "#c\n"),
+			NewTerminal("+"),
+			NewNonTerminal("Number", []*Node{NewTerminal("2")}),
+			NewTrivia("Space", "\n"),
+		}),
+	})
+	if assert.Len(t, ns, 1) {
+		n := ns[0]
+		assert.Equal(t, `(Sum {(~Space " ") "1"} {(~Space " ") (~Comment "#c\n") "+"} (Number {"2" (~Space "\n")}))`, n.Format())
+		assert.Equal(t, " 1 #c\n+2\n", n.Source())
+		assert.Equal(t, "1+2", n.Text())
+		assert.Equal(t, `(~Space " ")`, n.Children[0].Leading[0].Format())
+		assert.Len(t, n.Children[1].Leading, 2)
+		assert.Len(t, n.Children[2].Children[0].Trailing, 1)
+	}
+	ns = AttachTrivia([]*Node{NewNonTerminal("Empty", []*Node{NewTrivia("Space", " ")})})
+	assert.Equal(t, `(Empty (~Space " "))`, ns[0].Format())
+	assert.Equal(t, " ", ns[0].Source())
+}
+
+func TestTriviaEncoding(t *testing.T) {
+	tree := func() *Node {
+		return AttachTrivia([]*Node{NewNonTerminal("A", []*Node{
+			NewTrivia("Space", " ").WithSpan(0, 1),
+			NewTerminal("a").WithSpan(1, 2),
+			NewTrivia("Space", "\t").WithSpan(2, 3),
+		}).WithSpan(0, 3)})[0]
+	}
+	n := tree()
+	p := &Node{}
+	data, err := json.Marshal(n)
+	if assert.NoError(t, err) && assert.NoError(t, json.Unmarshal(data, p)) {
+		assert.Equal(t, n, p)
+	}
+	data, err = xml.Marshal(n)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `<nonterminal label="A" start="0" end="3"><terminal start="1" end="2"><leading><trivia label="Space" start="0" end="1"> </trivia></leading>a<trailing><trivia label="Space" start="2" end="3">&#x9;</trivia></trailing></terminal></nonterminal>`, string(data))
+		p = &Node{}
+		if assert.NoError(t, xml.Unmarshal(data, p)) {
+			assert.Equal(t, n, p)
+		}
+	}
+	a := n.FormatSpans()
+	assert.Equal(t, `(A@0:3 {(~Space@0:1 " ") "a"@1:2 (~Space@2:3 "\t")})`, a)
+	p, err = Parse(a)
+	if assert.NoError(t, err) {
+		assert.Equal(t, n, p)
+	}
+	p, err = Parse(`(A {(~Space " ") (!A "x") (~Space " ") (~Space " ")} {"b"})`)
+	if assert.NoError(t, err) {
+		assert.Len(t, p.Children[0].Leading, 1)
+		assert.Len(t, p.Children[0].Trailing, 2)
+		assert.Equal(t, " x  b", p.Source())
+	}
+	for _, source := range []string{`{(~Space " ")}`, `{"a" "b"}`, `{(A "a")}`, `{"a"`} {
+		_, err = Parse(source)
+		assert.Error(t, err, source)
+	}
+	p, err = Parse(`(A (~Space@0:1 " ") "a")`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Trivia, p.Children[0].Kind)
+		assert.Equal(t, 1, p.Children[0].End)
+	}
+}
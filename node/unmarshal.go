@@ -0,0 +1,137 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package node
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Unmarshal stores in the value pointed to by v the content of the parse tree
+// n. Struct fields tagged like `seared:"Number"` are set from the children of
+// the node having that label: slice fields from all of them, pointer fields
+// from the first one if any, and other fields from the first one, which must
+// exist. Values implementing encoding.TextUnmarshaler, strings, booleans and
+// numbers are set from the text matched by the node.
+func Unmarshal(n *Node, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("node: Unmarshal needs a non nil pointer, not %T", v)
+	}
+	return unmarshal(n, rv.Elem())
+}
+
+func unmarshal(n *Node, v reflect.Value) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(n.Text()))
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshal(n, v.Elem())
+	case reflect.Struct:
+		return unmarshalStruct(n, v)
+	case reflect.String:
+		v.SetString(n.Text())
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(n.Text())
+		if err != nil {
+			return unmarshalError(n, v, err)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(n.Text(), 0, v.Type().Bits())
+		if err != nil {
+			return unmarshalError(n, v, err)
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(n.Text(), 0, v.Type().Bits())
+		if err != nil {
+			return unmarshalError(n, v, err)
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(n.Text(), v.Type().Bits())
+		if err != nil {
+			return unmarshalError(n, v, err)
+		}
+		v.SetFloat(f)
+		return nil
+	}
+	return unmarshalError(n, v, fmt.Errorf("unsupported type"))
+}
+
+func unmarshalStruct(n *Node, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		label, ok := f.Tag.Lookup("seared")
+		if !ok || label == "-" || f.PkgPath != "" {
+			continue
+		}
+		children := n.Labeled(label)
+		fv := v.Field(i)
+		switch {
+		case fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalerType):
+			s := reflect.MakeSlice(fv.Type(), len(children), len(children))
+			for j, c := range children {
+				if err := unmarshal(c, s.Index(j)); err != nil {
+					return err
+				}
+			}
+			fv.Set(s)
+		case len(children) > 0:
+			if err := unmarshal(children[0], fv); err != nil {
+				return err
+			}
+		case fv.Kind() != reflect.Ptr:
+			return fmt.Errorf("node: cannot unmarshal %s into field %s.%s: no %s node found", describe(n), t.Name(), f.Name, label)
+		}
+	}
+	return nil
+}
+
+func describe(n *Node) string {
+	if n.Kind == Terminal {
+		return strconv.Quote(n.Value)
+	}
+	return n.Label + " node"
+}
+
+func unmarshalError(n *Node, v reflect.Value, err error) error {
+	return fmt.Errorf("node: cannot unmarshal %s into %s: %v", describe(n), v.Type(), err)
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package node
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type upper string
+
+func (u *upper) UnmarshalText(text []byte) error {
+	*u = upper(strings.ToUpper(string(text)))
+	return nil
+}
+
+type setting struct {
+	Name  upper  `seared:"Name"`
+	Value *value `seared:"Value"`
+}
+
+type value struct {
+	Number *int    `seared:"Number"`
+	Text   *string `seared:"Text"`
+	Flag   *bool   `seared:"Flag"`
+}
+
+type config struct {
+	Settings []setting `seared:"Setting"`
+	Version  float64   `seared:"Version"`
+}
+
+func terminals(label, text string) *Node {
+	ns := []*Node{}
+	for _, r := range text {
+		ns = append(ns, NewTerminal(string(r)))
+	}
+	return NewNonTerminal(label, ns)
+}
+
+func TestUnmarshal(t *testing.T) {
+	a := assert.New(t)
+	n := NewNonTerminal("Config", []*Node{
+		terminals("Version", "1.5"),
+		NewNonTerminal("Setting", []*Node{terminals("Name", "port"), NewTerminal("="), NewNonTerminal("Value", []*Node{terminals("Number", "80")})}),
+		NewTerminal(";"),
+		NewNonTerminal("Setting", []*Node{terminals("Name", "host"), NewTerminal("="), NewNonTerminal("Value", []*Node{terminals("Text", "local")})}),
+		NewNonTerminal("Setting", []*Node{terminals("Name", "debug")}),
+	})
+	var c config
+	if a.NoError(Unmarshal(n, &c)) {
+		a.Equal(1.5, c.Version)
+		if a.Len(c.Settings, 3) {
+			a.Equal(upper("PORT"), c.Settings[0].Name)
+			a.Equal(80, *c.Settings[0].Value.Number)
+			a.Nil(c.Settings[0].Value.Text)
+			a.Equal("local", *c.Settings[1].Value.Text)
+			a.Nil(c.Settings[2].Value)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	a := assert.New(t)
+	var c config
+	a.EqualError(Unmarshal(NewNonTerminal("Config", nil), &c), "node: cannot unmarshal Config node into field config.Version: no Version node found")
+	a.EqualError(Unmarshal(NewNonTerminal("Config", []*Node{terminals("Version", "x")}), &c), `node: cannot unmarshal Version node into float64: strconv.ParseFloat: parsing "x": invalid syntax`)
+	a.Error(Unmarshal(NewNonTerminal("Config", nil), c))
+	var n int
+	a.NoError(Unmarshal(terminals("Number", "0x10"), &n))
+	a.Equal(16, n)
+}
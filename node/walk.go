@@ -0,0 +1,125 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package node
+
+import "strings"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node *Node) (w Visitor)
+}
+
+// Walk traverses a parse tree in depth-first order: It starts by calling
+// v.Visit(n); n must not be nil. If the visitor w returned by v.Visit(n) is
+// not nil, Walk is invoked recursively with visitor w for each of the children
+// of n, followed by a call of w.Visit(nil).
+func Walk(v Visitor, n *Node) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+	for _, c := range n.Children {
+		Walk(v, c)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(*Node) bool
+
+func (f inspector) Visit(n *Node) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a parse tree in depth-first order: It starts by calling
+// f(n); n must not be nil. If f returns true, Inspect invokes f recursively
+// for each of the children of n, followed by a call of f(nil).
+func Inspect(n *Node, f func(*Node) bool) {
+	Walk(inspector(f), n)
+}
+
+// Find returns the first descendant of n labeled label in depth-first order,
+// or nil if there is none.
+func (n *Node) Find(label string) *Node {
+	var found *Node
+	for _, c := range n.Children {
+		Inspect(c, func(d *Node) bool {
+			if found == nil && d != nil && d.Kind != Terminal && d.Label == label {
+				found = d
+			}
+			return found == nil
+		})
+		if found != nil {
+			break
+		}
+	}
+	return found
+}
+
+// FindAll returns the descendants of n labeled label in depth-first order,
+// including those nested inside others found.
+func (n *Node) FindAll(label string) []*Node {
+	found := []*Node{}
+	for _, c := range n.Children {
+		Inspect(c, func(d *Node) bool {
+			if d != nil && d.Kind != Terminal && d.Label == label {
+				found = append(found, d)
+			}
+			return true
+		})
+	}
+	return found
+}
+
+// Labeled returns the non terminal children of n labeled label.
+func (n *Node) Labeled(label string) []*Node {
+	ns := []*Node{}
+	for _, c := range n.Children {
+		if c.Kind == NonTerminal && c.Label == label {
+			ns = append(ns, c)
+		}
+	}
+	return ns
+}
+
+// Text returns the concatenated text of the terminals of n, including the
+// input skipped by error nodes.
+func (n *Node) Text() string {
+	if n.Kind != NonTerminal {
+		return n.Value
+	}
+	var b strings.Builder
+	Inspect(n, func(d *Node) bool {
+		if d != nil && (d.Kind == Terminal || d.Kind == Error) {
+			b.WriteString(d.Value)
+		}
+		return true
+	})
+	return b.String()
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package node
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sum is the tree of "1+(2+3)"
+func sum() *Node {
+	return NewNonTerminal("Sum", []*Node{
+		NewNonTerminal("Number", []*Node{NewTerminal("1")}),
+		NewTerminal("+"),
+		NewNonTerminal("Sum", []*Node{
+			NewTerminal("("),
+			NewNonTerminal("Number", []*Node{NewTerminal("2")}),
+			NewTerminal("+"),
+			NewNonTerminal("Number", []*Node{NewTerminal("3")}),
+			NewTerminal(")"),
+		}),
+	})
+}
+
+type tracer struct {
+	events *[]string
+	depth  int
+}
+
+func (t tracer) Visit(n *Node) Visitor {
+	if n == nil {
+		*t.events = append(*t.events, strings.Repeat(" ", t.depth-1)+"<")
+		return nil
+	}
+	*t.events = append(*t.events, strings.Repeat(" ", t.depth)+n.Label+n.Value)
+	if n.Kind == Terminal {
+		return nil
+	}
+	return tracer{t.events, t.depth + 1}
+}
+
+func TestWalk(t *testing.T) {
+	events := []string{}
+	Walk(tracer{events: &events}, sum())
+	assert.Equal(t, []string{
+		"Sum", " Number", "  1", " <", " +", " Sum", "  (", "  Number", "   2", "  <", "  +", "  Number", "   3", "  <", "  )", " <", "<",
+	}, events)
+}
+
+func TestInspect(t *testing.T) {
+	a := assert.New(t)
+	labels := []string{}
+	Inspect(sum(), func(n *Node) bool {
+		if n == nil {
+			labels = append(labels, "<")
+			return false
+		}
+		labels = append(labels, n.Label)
+		return n.Label == "Sum"
+	})
+	a.Equal([]string{"Sum", "Number", "", "Sum", "", "Number", "", "Number", "", "<", "<"}, labels)
+}
+
+func TestFind(t *testing.T) {
+	a := assert.New(t)
+	n := sum()
+	a.Equal("1", n.Find("Number").Text())
+	a.Equal("(2+3)", n.Find("Sum").Text())
+	a.Nil(n.Find("Product"))
+	a.Nil(n.Find("1"))
+	numbers := n.FindAll("Number")
+	if a.Len(numbers, 3) {
+		a.Equal("3", numbers[2].Text())
+	}
+	a.Len(n.FindAll("Sum"), 1)
+	a.Equal("1+(2+3)", n.Text())
+	a.Equal("+", NewTerminal("+").Text())
+	a.Equal("1+?", NewNonTerminal("Sum", []*Node{NewTerminal("1"), NewTerminal("+"), NewError("Number", "?")}).Text())
+}
@@ -25,32 +25,134 @@
 
 package seared
 
-import "github.com/lalloni/seared/buffer"
+import (
+	"io"
+
+	"github.com/lalloni/seared/buffer"
+	"github.com/lalloni/seared/node"
+)
 
 type Parser struct {
-	name  string
-	main  Expression
-	debug bool
-	log   Log
+	name       string
+	main       Expression
+	debug      bool
+	log        Log
+	memoize    bool
+	cuts       bool
+	actions    bool
+	builder    *Builder
+	recoveries map[string]Expression
+	recovering bool
+	concrete   bool
+	shaping    Shaping
+	predictive bool
 }
 
+// NewParser returns a parser for the main expression built by the main
+// function, named after the function calling NewParser. It panics with the
+// GrammarErrors found in the grammar, like repetitions of expressions matching
+// empty input that would loop forever.
 func NewParser(main func(*Builder) Expression) *Parser {
 	_, name := callerKeyName()
-	parser := &Parser{name: name, log: StandardLog()}
-	parser.main = main(newBuilder(parser))
+	return NewNamedParser(name, main)
+}
+
+// NewNamedParser returns a parser named name for the main expression built by
+// the main function. It panics like NewParser.
+func NewNamedParser(name string, main func(*Builder) Expression) *Parser {
+	parser, err := BuildNamedParser(name, main)
+	if err != nil {
+		panic(err)
+	}
 	return parser
 }
 
+// BuildParser is like NewParser but returns the GrammarErrors found instead of
+// panicking.
+func BuildParser(main func(*Builder) Expression) (*Parser, error) {
+	_, name := callerKeyName()
+	return BuildNamedParser(name, main)
+}
+
+// BuildNamedParser is like NewNamedParser but returns the GrammarErrors found
+// instead of panicking.
+func BuildNamedParser(name string, main func(*Builder) Expression) (*Parser, error) {
+	parser := &Parser{name: name, log: StandardLog(), recoveries: map[string]Expression{}, predictive: true}
+	parser.builder = newBuilder(parser)
+	parser.main = main(parser.builder)
+	if errs := parser.nullableRepetitions(); errs != nil {
+		return nil, errs
+	}
+	parser.markLeftRecursive()
+	predictChoices(parser.main)
+	return parser, nil
+}
+
+// markLeftRecursive marks the left recursive rules built for p, which are the
+// only ones whose applications grow seeds.
+func (p *Parser) markLeftRecursive() {
+	roots := []Expression{}
+	for _, r := range p.builder.rules {
+		roots = append(roots, r)
+	}
+	rules := reachableRules(roots...)
+	nullables := nullableRules(rules)
+	for _, r := range rules {
+		r.leftRecursive = leftRecursive(r, nullables)
+	}
+}
+
+// valued tells whether the expressions of p produce values, which are only
+// needed by actions. Expressions built without a parser always do.
+func (p *Parser) valued() bool {
+	return p == nil || p.actions
+}
+
 func (p *Parser) Name() string {
 	return p.name
 }
 
 func (p *Parser) ParseBuffer(input buffer.Buffer) *Result {
-	return p.main.Apply(input, 0)
+	failures := map[int]bool{}
+	for {
+		s := newSession(p, input, failures)
+		result := p.main.Apply(s, 0)
+		if p.recovering && !result.Success {
+			f := s.farthest
+			if result.Thrown != nil {
+				f = result.Thrown.position
+			}
+			if f >= 0 && !failures[f] {
+				failures[f] = true
+				continue
+			}
+		}
+		result.Errors = s.errors
+		if p.concrete {
+			result.Nodes = node.AttachTrivia(result.Nodes)
+		}
+		return result
+	}
 }
 
 func (p *Parser) ParseString(input string) *Result {
-	return p.ParseBuffer(buffer.StringBuffer(input))
+	return p.ParseBuffer(buffer.UTF8Buffer(input))
+}
+
+// ParseReader parses the input read from r, decoding it as it is needed and
+// discarding the input the parse will not read anymore, so inputs bigger than
+// the available memory can be parsed as long as the grammar commits to its
+// choices often enough. The input matched by the rules, tokens and actions
+// being applied is kept until they end, so such inputs need a main expression
+// repeating the rules matching each part of them, whose Result only retains
+// the input read since the last choice the parse could have backtracked to.
+//
+// The error returned is the one that stopped reading the input, if any. Input
+// is not discarded in recovery mode.
+func (p *Parser) ParseReader(r io.Reader) (*Result, error) {
+	input := buffer.ReaderBuffer(r)
+	result := p.ParseBuffer(input)
+	return result, input.Err()
 }
 
 func (p *Parser) SetLog(log Log) {
@@ -60,3 +162,68 @@ func (p *Parser) SetLog(log Log) {
 func (p *Parser) SetDebug(debug bool) {
 	p.debug = debug
 }
+
+// SetMemoize enables or disables packrat parsing, which caches the result of
+// every rule application at each input position during a parse.
+func (p *Parser) SetMemoize(memoize bool) {
+	p.memoize = memoize
+}
+
+// SetRecovery sets the expression used to recover from the failures labeled
+// with label. When a Throw expression raises label, the recovery expression is
+// applied at the failure position, the error is recorded in the Errors of the
+// parse Result and, if it matches, the parse continues after its match.
+//
+// It returns the GrammarErrors found with the recovery expression, like
+// repetitions of expressions matching empty input in it or in the grammar
+// when the Throw expressions raising label recover without consuming input,
+// without setting it then.
+func (p *Parser) SetRecovery(label string, recovery func(*Builder) Expression) error {
+	previous, ok := p.recoveries[label]
+	p.recoveries[label] = recovery(p.builder)
+	if errs := p.nullableRepetitions(); errs != nil {
+		if ok {
+			p.recoveries[label] = previous
+		} else {
+			delete(p.recoveries, label)
+		}
+		return errs
+	}
+	p.markLeftRecursive()
+	return nil
+}
+
+// SetRecoveryMode enables or disables the recovery mode. When enabled, each
+// syntax error found makes the parse start over, this time skipping the wrong
+// input at the error position up to the synchronization expression of the
+// innermost enclosing rule having one, or up to the end of the input when it
+// does not match before. The skipped input is replaced by an
+// error node in the parse tree and the error is recorded in the Errors of the
+// parse Result, so the parse keeps going until it succeeds or fails at an
+// already known error position.
+func (p *Parser) SetRecoveryMode(recovering bool) {
+	p.recovering = recovering
+}
+
+// SetShaping sets the shaping of the nodes of the rules not setting their own
+// with the Shape option. Shaping keeps the trivia nodes of the concrete mode,
+// which are not counted as children.
+func (p *Parser) SetShaping(shaping Shaping) {
+	p.shaping = shaping
+}
+
+// SetPredictive enables or disables the predictive choices, which skip the
+// alternatives that can not match the next input character according to the
+// First set of each one, standing for their failures without applying them.
+// Choices are predictive by default, except in recovery mode.
+func (p *Parser) SetPredictive(predictive bool) {
+	p.predictive = predictive
+}
+
+// SetConcrete enables or disables the concrete mode, in which the input
+// matched by the rules dropping their nodes is kept as trivia nodes attached
+// to the neighbouring terminals, so the Source of the nodes of a parse Result
+// is the input matched byte for byte.
+func (p *Parser) SetConcrete(concrete bool) {
+	p.concrete = concrete
+}
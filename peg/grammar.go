@@ -0,0 +1,204 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package peg
+
+import "github.com/lalloni/seared"
+
+// The PEG notation grammar, as described by Bryan Ford in "Parsing Expression
+// Grammars: A Recognition-Based Syntactic Foundation" (2004), but allowing a
+// '-' right before the closing bracket of a class to stand for itself.
+
+func grammar(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(spacing(b), b.OneOrMore(definition(b)), b.End())
+	})
+}
+
+// fragment is a single expression, as compiled by Compile
+func fragment(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(spacing(b), expression(b), b.End())
+	})
+}
+
+func definition(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(identifier(b), leftArrow(b), expression(b))
+	})
+}
+
+func expression(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(sequence(b), b.ZeroOrMore(slash(b), sequence(b)))
+	})
+}
+
+func sequence(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.ZeroOrMore(prefix(b))
+	})
+}
+
+func prefix(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Optional(b.Choice(and(b), not(b))), suffix(b))
+	})
+}
+
+func suffix(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(primary(b), b.Optional(b.Choice(question(b), star(b), plus(b))))
+	})
+}
+
+func primary(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Choice(
+			b.Sequence(identifier(b), b.TestNot(leftArrow(b))),
+			b.Sequence(open(b), expression(b), closing(b)),
+			literal(b),
+			class(b),
+			dot(b))
+	})
+}
+
+func identifier(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(name(b), spacing(b))
+	})
+}
+
+func name(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(
+			b.Choice(b.Range('a', 'z'), b.Range('A', 'Z'), b.Rune('_')),
+			b.ZeroOrMore(b.Choice(b.Range('a', 'z'), b.Range('A', 'Z'), b.Range('0', '9'), b.Rune('_'))))
+	})
+}
+
+func literal(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(
+			b.Choice(
+				b.Sequence(b.Rune('\''), b.ZeroOrMore(b.TestNot(b.Rune('\'')), char(b)), b.Rune('\'')),
+				b.Sequence(b.Rune('"'), b.ZeroOrMore(b.TestNot(b.Rune('"')), char(b)), b.Rune('"'))),
+			spacing(b))
+	})
+}
+
+func class(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune('['), b.ZeroOrMore(b.TestNot(b.Rune(']')), interval(b)), b.Rune(']'), spacing(b))
+	})
+}
+
+func interval(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Choice(b.Sequence(char(b), b.Rune('-'), b.TestNot(b.Rune(']')), char(b)), char(b))
+	})
+}
+
+func char(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Choice(
+			b.Sequence(b.Rune('\\'), b.AnyOf(`nrt'"[]\-`)),
+			b.Sequence(b.Rune('\\'), b.Range('0', '2'), b.Range('0', '7'), b.Range('0', '7')),
+			b.Sequence(b.Rune('\\'), b.Range('0', '7'), b.Optional(b.Range('0', '7'))),
+			b.Sequence(b.TestNot(b.Rune('\\')), b.Any()))
+	})
+}
+
+func leftArrow(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Literal("<-"), spacing(b))
+	})
+}
+
+func slash(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune('/'), spacing(b))
+	})
+}
+
+func and(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune('&'), spacing(b))
+	})
+}
+
+func not(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune('!'), spacing(b))
+	})
+}
+
+func question(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune('?'), spacing(b))
+	})
+}
+
+func star(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune('*'), spacing(b))
+	})
+}
+
+func plus(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune('+'), spacing(b))
+	})
+}
+
+func open(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune('('), spacing(b))
+	})
+}
+
+func closing(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune(')'), spacing(b))
+	})
+}
+
+func dot(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune('.'), spacing(b))
+	})
+}
+
+func spacing(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.ZeroOrMore(b.Choice(b.AnyOf(" \t\r\n"), comment(b)))
+	}, b.DropNode())
+}
+
+func comment(b *seared.Builder) seared.Expression {
+	return b.Rule(func() seared.Expression {
+		return b.Sequence(b.Rune('#'), b.ZeroOrMore(b.TestNot(b.Rune('\n')), b.Any()))
+	})
+}
@@ -0,0 +1,356 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package peg builds parsers from grammars written in the textual PEG
+// notation, for example:
+//
+//	Sum    <- Term ([+-] Term)*
+//	Term   <- Factor ([*/] Factor)*
+//	Factor <- Number / '(' Sum ')'
+//	Number <- [0-9]+
+//
+// The first rule of the grammar is the main rule of the parser. Rules may be
+// defined in any order and text from '#' to the end of line is a comment.
+package peg
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lalloni/seared"
+	"github.com/lalloni/seared/location"
+)
+
+// Error is an error found in the source of a grammar
+type Error struct {
+	Location location.Location
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return e.Message + " at " + e.Location.String()
+}
+
+var (
+	bootstrap = seared.NewNamedParser("PEG", grammar)
+	fragments = seared.NewNamedParser("PEG expression", fragment)
+)
+
+// Parse returns a parser for the grammar written in PEG notation in source.
+func Parse(source string) (*seared.Parser, error) {
+	result := bootstrap.ParseString(source)
+	if !result.Success {
+		return nil, syntaxError(result)
+	}
+	c := &compiler{definitions: map[string]*seared.Result{}}
+	for _, d := range children(result) {
+		if d.Expression.Name() != "definition" {
+			continue
+		}
+		cs := children(d)
+		id, e := cs[0], cs[2]
+		n := text(id)
+		if _, ok := c.definitions[n]; ok {
+			return nil, newError(id, "rule "+strconv.Quote(n)+" redefined")
+		}
+		c.definitions[n] = e
+		c.order = append(c.order, n)
+		if c.main == "" {
+			c.main = n
+		}
+	}
+	for _, n := range c.order {
+		if err := c.check(c.definitions[n]); err != nil {
+			return nil, err
+		}
+	}
+	return seared.BuildNamedParser(c.main, func(b *seared.Builder) seared.Expression {
+		return c.rule(b, c.main)
+	})
+}
+
+// Compile returns the expression built by b for the single expression written
+// in PEG notation in source, whose identifiers stand for the expressions
+// returned by resolve, which returns nil for undefined ones.
+func Compile(b *seared.Builder, source string, resolve func(name string) seared.Expression) (seared.Expression, error) {
+	result := fragments.ParseString(source)
+	if !result.Success {
+		return nil, syntaxError(result)
+	}
+	c := &compiler{resolve: resolve}
+	var e *seared.Result
+	for _, r := range children(result) {
+		if r.Expression.Name() == "expression" {
+			e = r
+		}
+	}
+	if err := c.check(e); err != nil {
+		return nil, err
+	}
+	return c.compile(b, e), nil
+}
+
+// MustParse is like Parse but panics if the grammar can not be parsed.
+func MustParse(source string) *seared.Parser {
+	p, err := Parse(source)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Generate returns the Go source of a file of the package pkg holding a
+// standalone matcher for the grammar written in PEG notation in source, as
+// described by seared.Generate.
+func Generate(source, pkg, name string) ([]byte, error) {
+	p, err := Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	return seared.Generate(p, pkg, name)
+}
+
+func newError(r *seared.Result, message string) *Error {
+	return &Error{Location: r.Input.Location(r.Start), Message: message}
+}
+
+func syntaxError(r *seared.Result) *Error {
+	fs := r.FailedChildlessResults()
+	p := 0
+	for _, f := range fs {
+		if f.Start > p {
+			p = f.Start
+		}
+	}
+	es := []string{}
+	seen := map[string]bool{}
+	for _, f := range fs {
+		if e := f.Expression.Expectation(); f.Start == p && !seen[e] {
+			seen[e] = true
+			es = append(es, e)
+		}
+	}
+	found := "end of input"
+	if p < r.Input.Length() {
+		_, next := r.Input.Decode(p)
+		found = strconv.Quote(r.Input.String(p, next))
+	}
+	return &Error{
+		Location: r.Input.Location(p),
+		Message:  "syntax error: found " + found + " but expected " + strings.Join(es, " or "),
+	}
+}
+
+// children returns the successful results of the rules applied to match r,
+// skipping any nested inside those.
+func children(r *seared.Result) []*seared.Result {
+	rs := []*seared.Result{}
+	for _, c := range r.Results {
+		if !c.Success {
+			continue
+		}
+		if _, ok := c.Expression.(seared.Rule); ok {
+			rs = append(rs, c)
+		} else {
+			rs = append(rs, children(c)...)
+		}
+	}
+	return rs
+}
+
+// text returns the text matched by the name of an identifier result
+func text(identifier *seared.Result) string {
+	return children(identifier)[0].Match()
+}
+
+type compiler struct {
+	main        string
+	definitions map[string]*seared.Result
+	// order has the names of the definitions in source order
+	order []string
+	// resolve returns the expressions of the identifiers of fragments
+	resolve func(name string) seared.Expression
+}
+
+// check verifies that every rule referenced from r is defined
+func (c *compiler) check(r *seared.Result) error {
+	for _, cr := range children(r) {
+		if cr.Expression.Name() == "identifier" {
+			if n := text(cr); !c.defined(n) {
+				return newError(cr, "undefined rule "+strconv.Quote(n))
+			}
+			continue
+		}
+		if err := c.check(cr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) defined(name string) bool {
+	if c.resolve != nil {
+		return c.resolve(name) != nil
+	}
+	return c.definitions[name] != nil
+}
+
+func (c *compiler) rule(b *seared.Builder, name string) seared.Expression {
+	if c.resolve != nil {
+		return c.resolve(name)
+	}
+	return b.NamedRule(name, func() seared.Expression {
+		return c.compile(b, c.definitions[name])
+	})
+}
+
+func (c *compiler) compile(b *seared.Builder, r *seared.Result) seared.Expression {
+	cs := children(r)
+	switch r.Expression.Name() {
+	case "expression":
+		es := []seared.Expression{}
+		for _, s := range cs {
+			if s.Expression.Name() == "sequence" {
+				es = append(es, c.compile(b, s))
+			}
+		}
+		if len(es) == 1 {
+			return es[0]
+		}
+		return b.Choice(es...)
+	case "sequence":
+		es := []seared.Expression{}
+		for _, p := range cs {
+			es = append(es, c.compile(b, p))
+		}
+		switch len(es) {
+		case 0:
+			return b.Empty()
+		case 1:
+			return es[0]
+		}
+		return b.Sequence(es...)
+	case "prefix":
+		e := c.compile(b, cs[len(cs)-1])
+		if len(cs) > 1 {
+			switch cs[0].Expression.Name() {
+			case "and":
+				return b.Test(e)
+			case "not":
+				return b.TestNot(e)
+			}
+		}
+		return e
+	case "suffix":
+		e := c.compile(b, cs[0])
+		if len(cs) > 1 {
+			switch cs[1].Expression.Name() {
+			case "question":
+				return b.Optional(e)
+			case "star":
+				return b.ZeroOrMore(e)
+			case "plus":
+				return b.OneOrMore(e)
+			}
+		}
+		return e
+	case "primary":
+		for _, p := range cs {
+			switch p.Expression.Name() {
+			case "identifier":
+				return c.rule(b, text(p))
+			case "expression", "literal", "class":
+				return c.compile(b, p)
+			case "dot":
+				return b.Any()
+			}
+		}
+	case "literal":
+		rs := []rune{}
+		for _, ch := range cs {
+			if ch.Expression.Name() == "char" {
+				rs = append(rs, unescape(ch.Match()))
+			}
+		}
+		switch len(rs) {
+		case 0:
+			return b.Empty()
+		case 1:
+			return b.Rune(rs[0])
+		}
+		return b.Literal(string(rs))
+	case "class":
+		es := []seared.Expression{}
+		set := ""
+		for _, i := range cs {
+			if i.Expression.Name() != "interval" {
+				continue
+			}
+			rs := []rune{}
+			for _, ch := range children(i) {
+				rs = append(rs, unescape(ch.Match()))
+			}
+			if len(rs) == 1 {
+				set += string(rs[0])
+			} else {
+				es = append(es, b.Range(rs[0], rs[1]))
+			}
+		}
+		if set != "" {
+			if len([]rune(set)) == 1 {
+				es = append(es, b.Rune([]rune(set)[0]))
+			} else {
+				es = append(es, b.AnyOf(set))
+			}
+		}
+		switch len(es) {
+		case 0:
+			return b.TestNot(b.Empty())
+		case 1:
+			return es[0]
+		}
+		return b.Choice(es...)
+	}
+	panic("unexpected grammar result " + r.Expression.Name())
+}
+
+// unescape returns the rune represented by the text of a char result
+func unescape(s string) rune {
+	if len(s) < 2 || s[0] != '\\' {
+		return []rune(s)[0]
+	}
+	switch s[1] {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n, _ := strconv.ParseUint(s[1:], 8, 32)
+		return rune(n)
+	}
+	return rune(s[1])
+}
@@ -0,0 +1,176 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package peg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lalloni/seared"
+)
+
+const calculator = `
+# Arithmetic expressions
+Calculator <- Sum !.
+Sum        <- Term ([+-] Term)*
+Term       <- Factor ([*/] Factor)*
+Factor     <- Number / '(' Sum ")"
+Number     <- [0-9]+
+`
+
+func TestParseCalculator(t *testing.T) {
+	a := assert.New(t)
+	p, err := Parse(calculator)
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal("Calculator", p.Name())
+	cases := []struct {
+		input   string
+		success bool
+	}{
+		{"1", true},
+		{"1*10+1", true},
+		{"10*(2+1)", true},
+		{"a20", false},
+		{"", false},
+		{"2*1+(1+1*a)*2", false},
+	}
+	for _, c := range cases {
+		a.Equal(c.success, p.ParseString(c.input).Success, "parsing %q", c.input)
+	}
+	a.Equal(`(Calculator (Sum (Term (Factor (Number "1"))) "+" (Term (Factor (Number "2")) "*" (Factor (Number "3")))))`, p.ParseString("1+2*3").FormatNodeTree())
+}
+
+func TestParseOperators(t *testing.T) {
+	a := assert.New(t)
+	p := MustParse(`
+		Main    <- &'a' Word (', ' Word)* Ending? !.
+		Word    <- [a-zA-Z_]+ / "\"" (!["] .)* '"'
+		Ending  <- '\n' / '\041' / [\]\-.]
+		Unused  <- ''
+	`)
+	for _, s := range []string{"a", "ab, ac", "a, \"zz\"\n", "a!", "a-", "a]", "a."} {
+		a.True(p.ParseString(s).Success, "parsing %q", s)
+	}
+	for _, s := range []string{"b", "a,b", "a?"} {
+		a.False(p.ParseString(s).Success, "parsing %q", s)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	a := assert.New(t)
+	cases := []struct {
+		source  string
+		message string
+		line    int
+		column  int
+	}{
+		{"", `syntax error: found end of input but expected `, 1, 1},
+		{"A <- 'a'\nB <- [a-", `syntax error: found end of input but expected `, 2, 9},
+		{"A <- 'a' B\n", `undefined rule "B"`, 1, 10},
+		{"A <- B C\nB <- 'b'\nC <- D\nD <- 'd' X\nE <- F / G\nF <- 'f'", `undefined rule "X"`, 4, 10},
+		{"A <- 'a'\n\nA <- 'b'", `rule "A" redefined`, 3, 1},
+	}
+	for _, c := range cases {
+		p, err := Parse(c.source)
+		a.Nil(p)
+		if e, ok := err.(*Error); a.True(ok, "parsing %q", c.source) {
+			a.Contains(e.Message, c.message)
+			a.Equal(c.line, e.Location.Line)
+			a.Equal(c.column, e.Location.Column)
+		}
+	}
+	p, err := Parse("A <- B*\nB <- 'b'?")
+	a.Nil(p)
+	a.EqualError(err, `repeated expression may match empty input in B* at A`)
+}
+
+func TestGrammarString(t *testing.T) {
+	a := assert.New(t)
+	p, err := Parse(calculator)
+	if !a.NoError(err) {
+		return
+	}
+	canonical := `Calculator <- Sum !.
+Sum <- Term ([+-] Term)*
+Term <- Factor ([*/] Factor)*
+Factor <- Number / '(' Sum ')'
+Number <- [0-9]+
+`
+	a.Equal(canonical, p.Grammar().String())
+	source := `A <- (B / 'x\\'*) !(C D) &'\n'
+B <- [\]\-a-z-] ('' / .)?
+C <- '\''
+D <- [\[-\]]+
+`
+	p, err = Parse(source)
+	if a.NoError(err) {
+		printed := p.Grammar().String()
+		a.Equal(strings.Replace(source, `[\]\-a-z-]`, `([a-z] / [\]\--])`, 1), printed)
+		p, err = Parse(printed)
+		if a.NoError(err) {
+			a.Equal(printed, p.Grammar().String())
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	a := assert.New(t)
+	src, err := Generate(calculator, "calc", "")
+	if a.NoError(err) {
+		a.Contains(string(src), "package calc\n")
+		a.Contains(string(src), "func ParseCalculator(input string) *seared.Result {")
+		a.Contains(string(src), "func calculatorMatchNumber(input buffer.Buffer, start int) *seared.Result {")
+	}
+	_, err = Generate("Sum <- Sum '+' Number / Number\nNumber <- [0-9]+", "calc", "")
+	a.EqualError(err, `generating Sum: left recursive rule "Sum" is not supported`)
+}
+
+func TestCompile(t *testing.T) {
+	a := assert.New(t)
+	var e seared.Expression
+	p := seared.NewNamedParser("Fragment", func(b *seared.Builder) seared.Expression {
+		digit := b.NamedRule("Digit", func() seared.Expression { return b.Range('0', '9') })
+		var err error
+		e, err = Compile(b, ` Digit+ ('.' Digit+)? `, func(name string) seared.Expression {
+			if name == "Digit" {
+				return digit
+			}
+			return nil
+		})
+		a.NoError(err)
+		_, err = Compile(b, `Digit Other`, func(string) seared.Expression { return nil })
+		a.EqualError(err, `undefined rule "Digit" at position 0 (line 1, column 1)`)
+		_, err = Compile(b, `A <- B`, func(string) seared.Expression { return nil })
+		a.Error(err)
+		return e
+	})
+	a.True(p.ParseString("3.14").Success)
+	a.Equal(`(Digit "3") "." (Digit "1") (Digit "4")`, strings.Replace(p.ParseString("3.14").FormatNodeTree(), "\n", " ", -1))
+}
@@ -44,6 +44,20 @@ type Result struct {
 	Results []*Result
 	// Nodes are the parse trees produced
 	Nodes []*node.Node
+	// Values are the values produced by the actions applied, or the text
+	// matched by terminal expressions, only produced by parsers having actions
+	Values []interface{}
+	// Cut tells whether a cut operator was passed, committing the innermost
+	// enclosing choice to the current alternative
+	Cut bool
+	// Thrown is the labeled failure raised by a Throw expression which made
+	// this result fail
+	Thrown *SyntaxError
+	// Errors are the syntax errors the parse recovered from
+	Errors []*SyntaxError
+	// farthest is the farthest position a failure was found at while applying
+	// a rule
+	farthest int
 }
 
 func (r *Result) Match() string {
@@ -55,7 +69,10 @@ func (r *Result) Length() int {
 }
 
 func (r *Result) Error() string {
-	return "Invalid input '" + r.Input.String(r.Start, r.Start+1) + "' at " + r.Input.Location(r.Start).String() + ", expected " + r.Expression.Expectation()
+	if r.Thrown != nil {
+		return r.Thrown.Error()
+	}
+	return "Invalid input '" + found(r.Input, r.Start) + "' at " + r.Input.Location(r.Start).String() + ", expected " + r.Expression.Expectation()
 }
 
 func (r *Result) WithNodes(nodes ...*node.Node) *Result {
@@ -65,6 +82,19 @@ func (r *Result) WithNodes(nodes ...*node.Node) *Result {
 	return r
 }
 
+func (r *Result) WithValues(values ...interface{}) *Result {
+	r.Values = append(r.Values, values...)
+	return r
+}
+
+// Value returns the first value produced, if any.
+func (r *Result) Value() interface{} {
+	if len(r.Values) == 0 {
+		return nil
+	}
+	return r.Values[0]
+}
+
 func (r *Result) WithResults(results ...*Result) *Result {
 	for _, result := range results {
 		r.Results = append(r.Results, result)
@@ -72,11 +102,31 @@ func (r *Result) WithResults(results ...*Result) *Result {
 	return r
 }
 
+func (r *Result) WithCut(cut bool) *Result {
+	r.Cut = cut
+	return r
+}
+
+func (r *Result) WithThrown(thrown *SyntaxError) *Result {
+	r.Thrown = thrown
+	return r
+}
+
 func (r *Result) HasChildren() bool {
 	return len(r.Results) > 0
 }
 
+// ChildlessResults returns the results without children r is made of, where
+// the failures of Skipped expressions stand for those of the alternatives
+// skipped.
 func (r *Result) ChildlessResults() []*Result {
+	if x, ok := r.Expression.(*expression); ok && x.name == "Skipped" {
+		rs := []*Result{}
+		for _, l := range x.operands {
+			rs = append(rs, &Result{Expression: l, Input: r.Input, Start: r.Start, End: r.Start})
+		}
+		return rs
+	}
 	if !r.HasChildren() {
 		return []*Result{r}
 	}
@@ -169,21 +219,32 @@ func (r *Result) FarthestFailedResult() (result *Result) {
 }
 
 func (r *Result) BetterError() string {
+	if r.Thrown != nil {
+		return r.Thrown.Error()
+	}
 	ffr := r.FarthestFailedResult()
 	if ffr == nil {
 		return ""
 	}
-	frs := make([]*Result, 0)
+	return "Invalid input '" + found(r.Input, ffr.Start) + "' at " + r.Input.Location(ffr.Start).String() + ", expected " + r.expectedAt(ffr.Start)
+}
+
+// found returns the text of the rune at pos of input
+func found(input buffer.Buffer, pos int) string {
+	_, next := input.Decode(pos)
+	return input.String(pos, next)
+}
+
+// expectedAt describes the expectations of the failed childless results
+// starting at pos.
+func (r *Result) expectedAt(pos int) string {
+	ss := make([]string, 0)
 	for _, fr := range r.FailedChildlessResults() {
-		if fr.Start == ffr.Start {
-			frs = append(frs, fr)
+		if fr.Start == pos {
+			ss = append(ss, fr.Expression.Expectation())
 		}
 	}
-	ss := make([]string, 0)
-	for _, fr := range frs {
-		ss = append(ss, fr.Expression.Expectation())
-	}
-	return "Invalid input '" + r.Input.String(ffr.Start, ffr.Start+1) + "' at " + r.Input.Location(ffr.Start).String() + ", expected " + strings.Join(ss, " or ")
+	return strings.Join(ss, " or ")
 }
 
 func (r *Result) Depth() int {
@@ -204,6 +265,9 @@ func Success(expression Expression, input buffer.Buffer, start, end int) *Result
 }
 
 func Failure(expression Expression, input buffer.Buffer, start, end int) *Result {
+	if s, ok := input.(*session); ok {
+		s.reached(start)
+	}
 	return &Result{
 		Expression: expression,
 		Success:    false,
@@ -220,3 +284,11 @@ func ResultsNodes(results []*Result) []*node.Node {
 	}
 	return nodes
 }
+
+func ResultsValues(results []*Result) []interface{} {
+	values := []interface{}{}
+	for _, result := range results {
+		values = append(values, result.Values...)
+	}
+	return values
+}
@@ -39,6 +39,9 @@ type Rule interface {
 	SetExpression(expression Expression)
 	SetDropNode(b bool)
 	SetOmitNode(b bool)
+	SetMemoize(b bool)
+	SetSynchronization(sync Expression)
+	SetShaping(shaping Shaping)
 }
 
 type rule struct {
@@ -48,6 +51,19 @@ type rule struct {
 	expression Expression
 	dropNode   bool
 	omitNode   bool
+	memoize    bool
+	sync       Expression
+	shaping    Shaping
+	shaped     bool
+	// leftRecursive tells whether the rule may be applied again at the same
+	// input position while being applied
+	leftRecursive bool
+}
+
+// NewRule returns a rule named name matching expression, as used by generated
+// parsers to describe their rules.
+func NewRule(name string, expression Expression) Rule {
+	return newRule(name, nil, expression)
 }
 
 func newRule(name string, p *Parser, expression Expression) *rule {
@@ -55,6 +71,7 @@ func newRule(name string, p *Parser, expression Expression) *rule {
 		name:       name,
 		parser:     p,
 		expression: expression,
+		memoize:    true,
 	}
 }
 
@@ -74,30 +91,74 @@ func (r *rule) SetDropNode(b bool) {
 	r.dropNode = b
 }
 
+// SetShaping sets the shaping of the nodes of the rule, overriding the one of
+// its parser.
+func (r *rule) SetShaping(shaping Shaping) {
+	r.shaping = shaping
+	r.shaped = true
+}
+
 func (r *rule) SetOmitNode(b bool) {
 	r.omitNode = b
 }
 
+func (r *rule) SetMemoize(b bool) {
+	r.memoize = b
+}
+
+func (r *rule) SetSynchronization(sync Expression) {
+	r.sync = sync
+}
+
 func (r *rule) Apply(input buffer.Buffer, pos int) (result *Result) {
+	if s, ok := input.(*session); ok {
+		return s.applyRule(r, pos)
+	}
+	return r.apply(input, pos)
+}
+
+func (r *rule) apply(input buffer.Buffer, pos int) (result *Result) {
+	if s := tracking(input); s != nil {
+		s.enter(pos, true)
+		defer s.leave()
+	}
+	debug := r.parser != nil && r.parser.debug
 	var loc location.Location
-	if r.parser.debug {
+	if debug {
 		loc = input.Location(pos)
 		r.parser.log.Debugf("Trying %q at %s of %q", r.Name(), loc, input.Input())
 	}
+	s, _ := input.(*session)
+	farthest := -1
+	if s != nil {
+		farthest, s.farthest = s.farthest, -1
+	}
 	inner := r.expression.Apply(input, pos)
+	if s != nil {
+		farthest, s.farthest = s.farthest, farthest
+		s.reached(farthest)
+	}
 	if inner.Success {
 		result = Success(r, input, inner.Start, inner.End).WithResults(inner)
-		if !r.dropNode {
+		switch {
+		case inToken(input):
+		case !r.dropNode:
+			result.WithValues(inner.Values...)
 			if r.omitNode {
 				result.WithNodes(inner.Nodes...)
 			} else {
-				result.WithNodes(node.NewNonTerminal(r.Name(), inner.Nodes))
+				result.WithNodes(r.shape(inner.Nodes, inner.Start, inner.End)...)
 			}
+		case r.parser != nil && r.parser.concrete:
+			result.WithNodes(node.NewTrivia(r.Name(), input.String(inner.Start, inner.End)).WithSpan(inner.Start, inner.End))
 		}
+	} else if recovered := r.recover(s, pos, inner, farthest); recovered != nil {
+		result = recovered
 	} else {
-		result = Failure(r, input, inner.Start, inner.End).WithResults(inner)
+		result = Failure(r, input, inner.Start, inner.End).WithResults(inner).WithCut(inner.Cut).WithThrown(inner.Thrown)
 	}
-	if r.parser.debug {
+	result.farthest = farthest
+	if debug {
 		var s string
 		if result.Success {
 			s = fmt.Sprintf("succeed consuming %q", input.String(pos, result.End))
@@ -108,3 +169,40 @@ func (r *rule) Apply(input buffer.Buffer, pos int) (result *Result) {
 	}
 	return
 }
+
+// recover skips the input from the position f of a known syntax error that
+// made inner fail up to the end of the next match of the rule synchronization
+// expression, or up to the end of the input when it does not match before,
+// returning a successful result standing for the skipped input or nil when
+// recovering is not possible.
+func (r *rule) recover(s *session, pos int, inner *Result, f int) *Result {
+	if s == nil || r.sync == nil || len(s.failures) == 0 {
+		return nil
+	}
+	if inner.Thrown != nil {
+		f = inner.Thrown.position
+	} else if !s.failures[f] {
+		return nil
+	}
+	farthest := s.farthest
+	defer func() { s.farthest = farthest }()
+	q := f
+	sync := r.sync.Apply(s, q)
+	for !sync.Success && s.Has(q) {
+		_, q = s.Decode(q)
+		sync = r.sync.Apply(s, q)
+	}
+	end := sync.End
+	if !sync.Success {
+		end = q
+	}
+	if end <= pos {
+		return nil
+	}
+	e := &SyntaxError{Rule: r.Name(), Location: s.Location(f), Found: found(s, f), Expected: inner.expectedAt(f), position: f}
+	if inner.Thrown != nil {
+		e.Label = inner.Thrown.Label
+	}
+	s.report(e)
+	return Success(r, s, pos, end).WithResults(inner, sync).WithNodes(node.NewError(r.Name(), s.String(pos, end)).WithSpan(pos, end))
+}
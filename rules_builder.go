@@ -40,10 +40,12 @@ func newBuilder(parser *Parser) *Builder {
 type RuleOption func(Rule)
 
 type Builder struct {
-	parser *Parser
-	rules  map[string]Expression
+	parser  *Parser
+	rules   map[string]Expression
+	current Rule
 }
 
+// DropNode makes the rule produce neither nodes nor values.
 func (b *Builder) DropNode() RuleOption {
 	return func(r Rule) {
 		r.SetDropNode(true)
@@ -56,15 +58,56 @@ func (b *Builder) OmitNode() RuleOption {
 	}
 }
 
+// Shape sets the shaping of the nodes of the rule, overriding the one of the
+// parser.
+func (b *Builder) Shape(shaping Shaping) RuleOption {
+	return func(r Rule) {
+		r.SetShaping(shaping)
+	}
+}
+
+// NoMemoize excludes the rule from the packrat cache of its parser, useful
+// for rules that are cheap to evaluate or depend on context.
+func (b *Builder) NoMemoize() RuleOption {
+	return func(r Rule) {
+		r.SetMemoize(false)
+	}
+}
+
+// Synchronize sets the expression used to resynchronize the input after a
+// syntax error inside the rule when the parser is in recovery mode. The input
+// is skipped from the error position up to the end of the next match of sync,
+// or up to the end of the input when there is none.
+func (b *Builder) Synchronize(sync Expression) RuleOption {
+	return func(r Rule) {
+		r.SetSynchronization(sync)
+	}
+}
+
+// Rule returns the rule whose expression is built by the rule function and
+// which is named after the function calling Rule.
 func (b *Builder) Rule(rule func() Expression, options ...RuleOption) Expression {
 	key, name := callerKeyName()
+	return b.rule(key, name, rule, options)
+}
+
+// NamedRule returns the rule named name whose expression is built by the
+// rule function, for grammars not defined by one Go function per rule.
+func (b *Builder) NamedRule(name string, rule func() Expression, options ...RuleOption) Expression {
+	return b.rule(name, name, rule, options)
+}
+
+func (b *Builder) rule(key, name string, rule func() Expression, options []RuleOption) Expression {
 	r, ok := b.rules[key]
 	if ok {
 		return r
 	}
 	this := newRule(name, b.parser, nil)
 	b.rules[key] = this
+	parent := b.current
+	b.current = this
 	this.SetExpression(rule())
+	b.current = parent
 	for _, option := range options {
 		option(this)
 	}
@@ -43,7 +43,7 @@ func (b *Builder) Empty() (this Expression) {
 func (b *Builder) End() (this Expression) {
 	this = newExpression("End", "END", b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
-			if start >= input.Length() {
+			if !input.Has(start) {
 				return Success(this, input, start, start)
 			}
 			return Failure(this, input, start, start)
@@ -55,11 +55,11 @@ func (b *Builder) Rune(r rune) (this Expression) {
 	e := "'" + string(r) + "'"
 	this = newExpression("Rune", e, b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
-			if input.Rune(start) == r {
-				return Success(this, input, start, start+1).WithNodes(node.NewTerminal(string(r)))
+			if c, next := input.Decode(start); next > start && c == r {
+				return b.runeTerminal(this, input, start, next, r)
 			}
 			return Failure(this, input, start, start)
-		})
+		}).with(nil, r)
 	return
 }
 
@@ -67,12 +67,16 @@ func (b *Builder) Literal(literal string) (this Expression) {
 	e := "'" + literal + "'"
 	this = newExpression("Literal", e, b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
-			end := start + len([]rune(literal))
-			if input.String(start, end) == literal {
-				return Success(this, input, start, end).WithNodes(node.NewTerminal(literal))
+			end := start
+			for _, r := range literal {
+				c, next := input.Decode(end)
+				if next == end || c != r {
+					return Failure(this, input, start, start)
+				}
+				end = next
 			}
-			return Failure(this, input, start, start)
-		})
+			return b.terminal(this, input, start, end, literal)
+		}).with(nil, literal)
 	return
 }
 
@@ -80,20 +84,20 @@ func (b *Builder) Range(first, last rune) (this Expression) {
 	e := "[" + string(first) + "-" + string(last) + "]"
 	this = newExpression("Range", e, b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
-			r := input.Rune(start)
-			if r >= first && r <= last {
-				return Success(this, input, start, start+1).WithNodes(node.NewTerminal(string(r)))
+			r, next := input.Decode(start)
+			if next > start && r >= first && r <= last {
+				return b.runeTerminal(this, input, start, next, r)
 			}
 			return Failure(this, input, start, start)
-		})
+		}).with(nil, [2]rune{first, last})
 	return
 }
 
 func (b *Builder) Any() (this Expression) {
 	this = newExpression("Any", ".", b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
-			if start < input.Length() {
-				return Success(this, input, start, start+1).WithNodes(node.NewTerminal(string(input.Rune(start))))
+			if r, next := input.Decode(start); next > start {
+				return b.runeTerminal(this, input, start, next, r)
 			}
 			return Failure(this, input, start, start)
 		})
@@ -108,13 +112,39 @@ func (b *Builder) AnyOf(runes string) (this Expression) {
 	e := "[" + runes + "]"
 	this = newExpression("AnyOf", e, b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
-			r := input.Rune(start)
+			r, next := input.Decode(start)
+			if next == start {
+				return Failure(this, input, start, start)
+			}
 			for _, rr := range runes {
 				if r == rr {
-					return Success(this, input, start, start+1).WithNodes(node.NewTerminal(string(r)))
+					return b.runeTerminal(this, input, start, next, r)
 				}
 			}
 			return Failure(this, input, start, start)
-		})
+		}).with(nil, runes)
 	return
 }
+
+// terminal returns the result of the terminal expression this matching text
+// from start to end of input, with its node and value unless inside a token.
+func (b *Builder) terminal(this Expression, input buffer.Buffer, start, end int, text string) *Result {
+	result := Success(this, input, start, end)
+	if inToken(input) {
+		return result
+	}
+	result.WithNodes(node.NewTerminal(text).WithSpan(start, end))
+	if b.parser.valued() {
+		result.WithValues(text)
+	}
+	return result
+}
+
+// runeTerminal is like terminal for the text of the rune r, which is only
+// built when needed.
+func (b *Builder) runeTerminal(this Expression, input buffer.Buffer, start, end int, r rune) *Result {
+	if inToken(input) {
+		return Success(this, input, start, end)
+	}
+	return b.terminal(this, input, start, end, string(r))
+}
@@ -40,16 +40,22 @@ func (b *Builder) Sequence(expressions ...Expression) (this Expression) {
 		func(input buffer.Buffer, start int) (result *Result) {
 			children := []*Result{}
 			next := start
+			cut := false
 			for _, expression := range expressions {
 				result = expression.Apply(input, next)
 				children = append(children, result)
+				cut = cut || result.Cut
 				if !result.Success {
-					return Failure(this, input, start, result.End).WithResults(children...)
+					return Failure(this, input, start, result.End).WithResults(children...).WithCut(cut).WithThrown(result.Thrown)
 				}
 				next = result.End
 			}
-			return Success(this, input, start, next).WithResults(children...).WithNodes(ResultsNodes(children)...)
-		})
+			result = Success(this, input, start, next).WithResults(children...).WithNodes(ResultsNodes(children)...).WithCut(cut)
+			if b.parser.valued() {
+				result.WithValues(ResultsValues(children)...)
+			}
+			return result
+		}).with(expressions, nil)
 	return
 }
 
@@ -58,18 +64,42 @@ func (b *Builder) Choice(expressions ...Expression) (this Expression) {
 		panic("Choice rules must have inner rules")
 	}
 	e := strings.Join(expectations(expressions), "/")
+	all := &alternatives{}
+	for i := range expressions {
+		all.indexes = append(all.indexes, i)
+	}
 	this = newExpression("Choice", e, b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
+			if s := tracking(input); s != nil {
+				s.enter(start, false)
+				defer s.leave()
+			}
+			candidates := all
+			if s, ok := input.(*session); ok && s.parser.predictive && !s.parser.recovering {
+				if d := this.(*expression).dispatch; d != nil {
+					candidates = d.candidates(input, start)
+				}
+			}
 			children := []*Result{}
-			for _, expression := range expressions {
-				result = expression.Apply(input, start)
+			for i, index := range candidates.indexes {
+				if candidates.skipped != nil && candidates.skipped[i] != nil {
+					children = append(children, Failure(candidates.skipped[i], input, start, start))
+				}
+				result = expressions[index].Apply(input, start)
 				children = append(children, result)
 				if result.Success {
-					return Success(this, input, start, result.End).WithResults(children...).WithNodes(result.Nodes...)
+					return Success(this, input, start, result.End).WithResults(children...).WithNodes(result.Nodes...).WithValues(result.Values...)
+				}
+				if result.Cut || result.Thrown != nil {
+					return Failure(this, input, start, result.End).WithResults(children...).WithCut(result.Cut).WithThrown(result.Thrown)
 				}
 			}
+			if candidates.skipped != nil && candidates.skipped[len(candidates.indexes)] != nil {
+				result = Failure(candidates.skipped[len(candidates.indexes)], input, start, start)
+				children = append(children, result)
+			}
 			return Failure(this, input, start, result.End).WithResults(children...)
-		})
+		}).with(expressions, nil)
 	return
 }
 
@@ -90,20 +120,35 @@ func (b *Builder) ZeroOrMore(expressions ...Expression) (this Expression) {
 	}
 	this = newExpression("ZeroOrMore", e, b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
+			s := tracking(input)
+			if s != nil {
+				s.enter(start, false)
+				defer s.leave()
+			}
 			children := []*Result{}
 			next := start
 			for {
 				result = expression.Apply(input, next)
 				children = append(children, result)
 				if !result.Success {
+					if result.Cut || result.Thrown != nil {
+						return Failure(this, input, start, result.End).WithResults(children...).WithCut(result.Cut).WithThrown(result.Thrown)
+					}
 					if len(children) > 1 {
 						children = children[0 : len(children)-1]
 					}
-					return Success(this, input, start, next).WithResults(children...).WithNodes(ResultsNodes(children)...)
+					result = Success(this, input, start, next).WithResults(children...).WithNodes(ResultsNodes(children)...)
+					if b.parser.valued() {
+						result.WithValues(ResultsValues(children)...)
+					}
+					return result
 				}
 				next = result.End
+				if s != nil {
+					s.advance(next)
+				}
 			}
-		})
+		}).with([]Expression{expression}, nil)
 	return
 }
 
@@ -124,6 +169,11 @@ func (b *Builder) OneOrMore(expressions ...Expression) (this Expression) {
 	}
 	this = newExpression("OneOrMore", e, b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
+			s := tracking(input)
+			if s != nil {
+				s.enter(start, false)
+				defer s.leave()
+			}
 			children := []*Result{}
 			next := start
 			matched := false
@@ -131,16 +181,23 @@ func (b *Builder) OneOrMore(expressions ...Expression) (this Expression) {
 				result = expression.Apply(input, next)
 				children = append(children, result)
 				if !result.Success {
-					if matched {
+					if matched && !result.Cut && result.Thrown == nil {
 						c := children[0 : len(children)-1]
-						return Success(this, input, start, next).WithResults(c...).WithNodes(ResultsNodes(c)...)
+						result = Success(this, input, start, next).WithResults(c...).WithNodes(ResultsNodes(c)...)
+						if b.parser.valued() {
+							result.WithValues(ResultsValues(c)...)
+						}
+						return result
 					}
-					return Failure(this, input, start, result.End).WithResults(children...)
+					return Failure(this, input, start, result.End).WithResults(children...).WithCut(result.Cut).WithThrown(result.Thrown)
 				}
 				next = result.End
 				matched = true
+				if s != nil {
+					s.advance(next)
+				}
 			}
-		})
+		}).with([]Expression{expression}, nil)
 	return
 }
 
@@ -161,10 +218,17 @@ func (b *Builder) Optional(expressions ...Expression) (this Expression) {
 	}
 	this = newExpression("Optional", e, b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
+			if s := tracking(input); s != nil {
+				s.enter(start, false)
+				defer s.leave()
+			}
 			inner := expression.Apply(input, start)
-			result = Success(this, input, start, inner.End).WithResults(inner).WithNodes(inner.Nodes...)
+			if !inner.Success && (inner.Cut || inner.Thrown != nil) {
+				return Failure(this, input, start, inner.End).WithResults(inner).WithCut(inner.Cut).WithThrown(inner.Thrown)
+			}
+			result = Success(this, input, start, inner.End).WithResults(inner).WithNodes(inner.Nodes...).WithValues(inner.Values...)
 			return
-		})
+		}).with([]Expression{expression}, nil)
 	return
 }
 
@@ -185,12 +249,16 @@ func (b *Builder) Test(expressions ...Expression) (this Expression) {
 	}
 	this = newExpression("Test", e, b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
+			if s := tracking(input); s != nil {
+				s.enter(start, true)
+				defer s.leave()
+			}
 			result = expression.Apply(input, start)
 			if result.Success {
 				return Success(this, input, start, start).WithResults(result)
 			}
-			return Failure(this, input, start, result.End).WithResults(result)
-		})
+			return Failure(this, input, start, result.End).WithResults(result).WithThrown(result.Thrown)
+		}).with([]Expression{expression}, nil)
 	return
 }
 
@@ -211,11 +279,132 @@ func (b *Builder) TestNot(expressions ...Expression) (this Expression) {
 	}
 	this = newExpression("TestNot", e, b.parser,
 		func(input buffer.Buffer, start int) (result *Result) {
+			if s := tracking(input); s != nil {
+				s.enter(start, true)
+				defer s.leave()
+			}
 			result = expression.Apply(input, start)
 			if !result.Success {
+				if result.Thrown != nil {
+					return Failure(this, input, start, result.End).WithResults(result).WithThrown(result.Thrown)
+				}
 				return Success(this, input, start, start).WithResults(result)
 			}
 			return Failure(this, input, start, result.End).WithResults(result)
+		}).with([]Expression{expression}, nil)
+	return
+}
+
+// Cut commits the innermost enclosing choice, repetition or option to the
+// alternative being matched so it will not backtrack to try any other when
+// what follows the cut fails, as described by Mizushima, Maeda & Yamaguchi.
+// Such a committed failure is not backtracked from by any enclosing rule,
+// choice, repetition or option either, so it is the one reported, while
+// predicates still backtrack from it as they never consume input.
+func (b *Builder) Cut() (this Expression) {
+	if b.parser != nil {
+		b.parser.cuts = true
+	}
+	this = newExpression("Cut", "^", b.parser,
+		func(input buffer.Buffer, start int) (result *Result) {
+			if s := tracking(input); s != nil {
+				s.cut(start)
+			}
+			return Success(this, input, start, start).WithCut(true)
 		})
 	return
 }
+
+// Throw raises a failure labeled with label that no choice, repetition or
+// option will backtrack from, as described by Maidl, Mascarenhas, Medeiros &
+// Ierusalimschy. When the parser has a recovery expression for the label it is
+// applied instead and the failure is recorded as a recovered syntax error.
+func (b *Builder) Throw(label string) (this Expression) {
+	rule := ""
+	if b.current != nil {
+		rule = b.current.Name()
+	}
+	this = newExpression("Throw", "%{"+label+"}", b.parser,
+		func(input buffer.Buffer, start int) (result *Result) {
+			thrown := NewLabeledError(label, rule, input, start)
+			if b.parser != nil {
+				if recovery, ok := b.parser.recoveries[label]; ok {
+					inner := recovery.Apply(input, start)
+					if inner.Success {
+						if s, ok := input.(*session); ok {
+							s.report(thrown)
+						}
+						return Success(this, input, start, inner.End).WithResults(inner).WithNodes(inner.Nodes...).WithValues(inner.Values...)
+					}
+					return Failure(this, input, start, start).WithResults(inner).WithThrown(thrown)
+				}
+			}
+			return Failure(this, input, start, start).WithThrown(thrown)
+		}).with(nil, throwArgument{label: label, rule: rule})
+	return
+}
+
+// ActionFunc computes the value of a successful result from the values
+// produced by the expressions it is composed of.
+type ActionFunc func(result *Result, values []interface{}) interface{}
+
+// Action applies expression and, when it succeeds, replaces the values it
+// produced by the one computed by action. Expressions only produce values
+// when their parser has actions.
+func (b *Builder) Action(expression Expression, action ActionFunc) (this Expression) {
+	if b.parser != nil {
+		b.parser.actions = true
+	}
+	this = newExpression("Action", expression.Expectation(), b.parser,
+		func(input buffer.Buffer, start int) (result *Result) {
+			if s := streaming(input); s != nil {
+				s.pin(start)
+				defer s.leave()
+			}
+			inner := expression.Apply(input, start)
+			if !inner.Success {
+				return Failure(this, input, start, inner.End).WithResults(inner).WithCut(inner.Cut).WithThrown(inner.Thrown)
+			}
+			result = Success(this, input, start, inner.End).WithResults(inner).WithNodes(inner.Nodes...).WithCut(inner.Cut)
+			if inToken(input) {
+				return result
+			}
+			return result.WithValues(action(result, inner.Values))
+		}).with([]Expression{expression}, action)
+	return
+}
+
+// Token matches the expressions in sequence producing a single terminal node
+// holding the whole text matched, which is also its value, instead of the
+// nodes and values of the expressions, which are not even built.
+func (b *Builder) Token(expressions ...Expression) (this Expression) {
+	var expression Expression
+	switch len(expressions) {
+	case 0:
+		panic("Token rules must have inner rules")
+	case 1:
+		expression = expressions[0]
+	default:
+		expression = b.Sequence(expressions...)
+	}
+	this = newExpression("Token", expression.Expectation(), b.parser,
+		func(input buffer.Buffer, start int) (result *Result) {
+			s, _ := input.(*session)
+			if s != nil {
+				if s.stream != nil {
+					s.pin(start)
+					defer s.leave()
+				}
+				s.tokens++
+			}
+			inner := expression.Apply(input, start)
+			if s != nil {
+				s.tokens--
+			}
+			if !inner.Success {
+				return Failure(this, input, start, inner.End).WithResults(inner).WithCut(inner.Cut).WithThrown(inner.Thrown)
+			}
+			return b.terminal(this, input, start, inner.End, input.String(start, inner.End)).WithResults(inner).WithCut(inner.Cut)
+		}).with([]Expression{expression}, nil)
+	return
+}
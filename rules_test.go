@@ -26,11 +26,15 @@
 package seared
 
 import (
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/lalloni/seared/buffer"
+	"github.com/lalloni/seared/grammar"
+	"github.com/lalloni/seared/node"
 )
 
 func ruleM(matcher Matcher) Expression {
@@ -530,3 +534,846 @@ func TestEmpty(t *testing.T) {
 	a.Equal(2, result.End)
 	a.Equal(0, len(result.Results))
 }
+
+func counted(expression Expression, count *int) Expression {
+	return ruleM(func(input buffer.Buffer, pos int) (result *Result) {
+		*count++
+		return expression.Apply(input, pos)
+	})
+}
+
+func TestMemoize(t *testing.T) {
+	a := assert.New(t)
+	count := 0
+	grammar := func(memoize bool) func(*Builder) Expression {
+		inner := func(b *Builder) Expression {
+			options := []RuleOption{}
+			if !memoize {
+				options = append(options, b.NoMemoize())
+			}
+			return b.Rule(func() Expression {
+				return counted(b.Rune('b'), &count)
+			}, options...)
+		}
+		return func(b *Builder) Expression {
+			return b.Rule(func() Expression {
+				return b.Choice(b.Sequence(inner(b), b.Rune('x')), b.Sequence(inner(b), b.Rune('y')))
+			})
+		}
+	}
+
+	p := NewParser(grammar(true))
+	a.True(p.ParseString("by").Success)
+	a.Equal(2, count)
+
+	count = 0
+	p.SetMemoize(true)
+	a.True(p.ParseString("by").Success)
+	a.Equal(1, count)
+
+	count = 0
+	a.True(p.ParseString("by").Success)
+	a.Equal(1, count, "cache must not outlive a parse")
+
+	count = 0
+	p = NewParser(grammar(false))
+	p.SetMemoize(true)
+	a.True(p.ParseString("by").Success)
+	a.Equal(2, count)
+}
+
+func LeftSum(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Choice(b.Sequence(LeftSum(b), b.Rune('+'), LeftNumber(b)), LeftNumber(b))
+	})
+}
+
+func LeftNumber(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Range('0', '9')
+	})
+}
+
+func LeftDifference(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Choice(b.Sequence(LeftOperand(b), b.Rune('-'), LeftNumber(b)), LeftNumber(b))
+	})
+}
+
+func LeftOperand(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return LeftDifference(b)
+	})
+}
+
+func LeftMixed(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Choice(b.Sequence(LeftMixedHead(b), b.Rune('b')), b.Rune('c'))
+	})
+}
+
+func LeftMixedHead(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Choice(b.Sequence(LeftMixedHead(b), b.Rune('a')), LeftMixed(b), b.Rune('a'))
+	})
+}
+
+func TestLeftRecursion(t *testing.T) {
+	a := assert.New(t)
+	cases := []struct {
+		main  func(*Builder) Expression
+		input string
+		end   int
+		tree  string
+	}{
+		{LeftSum, "1", 1, `(LeftSum (LeftNumber "1"))`},
+		{LeftSum, "1+2+3", 5, `(LeftSum (LeftSum (LeftSum (LeftNumber "1")) "+" (LeftNumber "2")) "+" (LeftNumber "3"))`},
+		{LeftSum, "1+2+", 3, `(LeftSum (LeftSum (LeftNumber "1")) "+" (LeftNumber "2"))`},
+		{LeftDifference, "1-2-3", 5, `(LeftDifference (LeftOperand (LeftDifference (LeftOperand (LeftDifference (LeftNumber "1"))) "-" (LeftNumber "2"))) "-" (LeftNumber "3"))`},
+		{LeftMixed, "ab", 2, `(LeftMixed (LeftMixedHead "a") "b")`},
+		{LeftMixed, "cb", 2, `(LeftMixed (LeftMixedHead (LeftMixed "c")) "b")`},
+		{LeftMixed, "abb", 3, `(LeftMixed (LeftMixedHead (LeftMixed (LeftMixedHead "a") "b")) "b")`},
+	}
+	for _, memoize := range []bool{false, true} {
+		for _, c := range cases {
+			p := NewParser(c.main)
+			p.SetMemoize(memoize)
+			result := p.ParseString(c.input)
+			if a.True(result.Success, "parsing %q", c.input) {
+				a.Equal(c.end, result.End)
+				a.Equal(c.tree, result.FormatNodeTree())
+			}
+		}
+	}
+	a.False(NewParser(LeftSum).ParseString("+").Success)
+	p := NewParser(LeftDifference)
+	for _, r := range reachableRules(p.main) {
+		a.Equal(r.Name() != "LeftNumber", r.leftRecursive, r.Name())
+	}
+}
+
+func TestCut(t *testing.T) {
+	a := assert.New(t)
+	grammar := func(cut bool) func(*Builder) Expression {
+		return func(b *Builder) Expression {
+			return b.Rule(func() Expression {
+				first := []Expression{b.Rune('a')}
+				if cut {
+					first = append(first, b.Cut())
+				}
+				first = append(first, b.Rune('b'))
+				return b.Sequence(b.Choice(b.Sequence(first...), b.Sequence(b.Rune('a'), b.Rune('c'))), b.End())
+			})
+		}
+	}
+
+	result := NewParser(grammar(false)).ParseString("ac")
+	a.True(result.Success)
+
+	result = NewParser(grammar(true)).ParseString("ab")
+	a.True(result.Success)
+	a.False(result.Cut, "cut must not escape its choice")
+
+	result = NewParser(grammar(true)).ParseString("ac")
+	a.False(result.Success)
+	a.Equal("Invalid input 'c' at position 1 (line 1, column 2), expected 'b'", result.BetterError())
+}
+
+func TestCutRepetition(t *testing.T) {
+	a := assert.New(t)
+	b := newBuilder(nil)
+	i := buffer.StringBuffer("abab ac")
+	item := b.Sequence(b.Rune('a'), b.Cut(), b.Rune('b'))
+
+	result := b.ZeroOrMore(item).Apply(i, 0)
+	a.True(result.Success)
+	a.Equal(4, result.End)
+
+	result = b.ZeroOrMore(item).Apply(i, 5)
+	a.False(result.Success)
+
+	result = b.OneOrMore(item).Apply(i, 5)
+	a.False(result.Success)
+
+	result = b.Optional(item).Apply(i, 5)
+	a.False(result.Success)
+
+	result = b.Optional(item).Apply(i, 4)
+	a.True(result.Success)
+	a.Equal(4, result.End)
+}
+
+func CutStatements(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Sequence(b.ZeroOrMore(CutStatement(b)), b.End())
+	})
+}
+
+func CutStatement(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Choice(
+			b.Sequence(CutWord(b), b.Rune('='), b.Cut(), CutWord(b), b.Rune(';')),
+			b.Sequence(CutWord(b), b.Rune(';')))
+	})
+}
+
+func CutWord(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.OneOrMore(b.Range('a', 'z'))
+	})
+}
+
+func TestCutDiscardsMemo(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(CutStatements)
+	p.SetMemoize(true)
+	input := "a=b;c;d=e;"
+	s := newSession(p, buffer.StringBuffer(input), nil)
+	result := p.main.Apply(s, 0)
+	a.True(result.Success)
+	a.Equal(6, s.pruned)
+	for pos := range s.memo {
+		a.True(pos >= 6, "memo for position %d should have been discarded", pos)
+	}
+	a.Equal(result.FormatNodeTree(), p.ParseString(input).FormatNodeTree())
+}
+
+func TestCutAcrossRules(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(CutStatements)
+	result := p.ParseString("a=b;a=b;a=b;a=b;a=b;c=;")
+	a.False(result.Success)
+	a.True(result.Cut, "committed failure must reach the parse result")
+	a.Equal("Invalid input ';' at position 22 (line 1, column 23), expected [a-z]", result.BetterError())
+
+	result = p.ParseString("a=b;c;")
+	a.True(result.Success)
+	a.False(result.Cut)
+
+	p = NewParser(func(b *Builder) Expression {
+		return b.Choice(b.Sequence(b.Optional(CutStatement(b)), b.End()), b.Literal("a=;"))
+	})
+	result = p.ParseString("a=;")
+	a.False(result.Success)
+	a.Equal("Invalid input ';' at position 2 (line 1, column 3), expected [a-z]", result.BetterError())
+}
+
+func LabeledStatements(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Sequence(b.ZeroOrMore(LabeledStatement(b)), b.End())
+	})
+}
+
+func LabeledStatement(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Sequence(CutWord(b), b.Rune('='), b.Choice(CutWord(b), b.Throw("MissingValue")), b.Rune(';'))
+	})
+}
+
+func TestThrow(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(LabeledStatements)
+
+	result := p.ParseString("a=b;c=d;")
+	a.True(result.Success)
+	a.Nil(result.Thrown)
+	a.Empty(result.Errors)
+
+	result = p.ParseString("a=b;c=;")
+	a.False(result.Success)
+	if a.NotNil(result.Thrown) {
+		a.Equal("MissingValue", result.Thrown.Label)
+		a.Equal("LabeledStatement", result.Thrown.Rule)
+		a.Equal(6, result.Thrown.Location.Position)
+	}
+	a.Equal("MissingValue at position 6 (line 1, column 7) in rule LabeledStatement", result.BetterError())
+	a.Equal("%{MissingValue}", newBuilder(nil).Throw("MissingValue").Expectation())
+}
+
+func TestThrowRecovery(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(LabeledStatements)
+	a.NoError(p.SetRecovery("MissingValue", func(b *Builder) Expression {
+		return b.ZeroOrMore(b.TestNot(b.Rune(';')), b.Any())
+	}))
+
+	result := p.ParseString("a=;b=c;d=!!;")
+	a.True(result.Success)
+	a.Nil(result.Thrown)
+	if a.Len(result.Errors, 2) {
+		a.Equal("MissingValue", result.Errors[0].Label)
+		a.Equal(2, result.Errors[0].Location.Position)
+		a.Equal(9, result.Errors[1].Location.Position)
+	}
+	a.Equal(`(LabeledStatements (LabeledStatement (CutWord "a") "=" ";") (LabeledStatement (CutWord "b") "=" (CutWord "c") ";") (LabeledStatement (CutWord "d") "=" "!" "!" ";"))`, result.FormatNodeTree())
+}
+
+func SyncStatements(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Sequence(b.ZeroOrMore(SyncStatement(b)), b.End())
+	})
+}
+
+func SyncStatement(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		return b.Sequence(CutWord(b), b.Rune('='), CutWord(b), b.Rune(';'))
+	}, b.Synchronize(b.Rune(';')))
+}
+
+func TestRecoveryMode(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(SyncStatements)
+
+	result := p.ParseString("a=b;c==;d=e;f=;")
+	a.False(result.Success)
+	a.Empty(result.Errors)
+
+	p.SetRecoveryMode(true)
+	result = p.ParseString("a=b;c==;d=e;f=;")
+	a.True(result.Success)
+	if a.Len(result.Errors, 2) {
+		a.Equal("Invalid input '=' at position 6 (line 1, column 7), expected [a-z] in rule SyncStatement", result.Errors[0].Error())
+		a.Equal(14, result.Errors[1].Location.Position)
+	}
+	a.Equal(`(SyncStatements (SyncStatement (CutWord "a") "=" (CutWord "b") ";") (!SyncStatement "c==;") (SyncStatement (CutWord "d") "=" (CutWord "e") ";") (!SyncStatement "f=;"))`, result.FormatNodeTree())
+
+	result = p.ParseString("c==;a=b")
+	a.True(result.Success)
+	if a.Len(result.Errors, 2) {
+		a.Equal(2, result.Errors[0].Location.Position)
+		a.Equal("Invalid input '' at position 7 (line 1, column 8), expected ';' in rule SyncStatement", result.Errors[1].Error())
+	}
+	a.Equal(`(SyncStatements (!SyncStatement "c==;") (!SyncStatement "a=b"))`, result.FormatNodeTree())
+
+	result = p.ParseString("a=b;c=d;e")
+	a.True(result.Success)
+	a.Len(result.Errors, 1)
+	a.Equal(`(SyncStatements (SyncStatement (CutWord "a") "=" (CutWord "b") ";") (SyncStatement (CutWord "c") "=" (CutWord "d") ";") (!SyncStatement "e"))`, result.FormatNodeTree())
+}
+
+type releasingStream struct {
+	buffer.Stream
+	released int
+}
+
+func (s *releasingStream) Release(pos int) {
+	if pos > s.released {
+		s.released = pos
+	}
+	s.Stream.Release(pos)
+}
+
+func TestParseReader(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(func(b *Builder) Expression {
+		return b.Sequence(b.ZeroOrMore(CutStatement(b)), b.End())
+	})
+	input := strings.Repeat("a=b;c;", 1000)
+	result, err := p.ParseReader(strings.NewReader(input))
+	a.NoError(err)
+	a.True(result.Success)
+	a.Equal(len(input), result.End)
+
+	s := &releasingStream{Stream: buffer.ReaderBuffer(strings.NewReader(input))}
+	result = p.ParseBuffer(s)
+	a.True(result.Success)
+	a.Equal(len(input), s.released)
+	a.Equal("", s.Input())
+	a.Equal(p.ParseString(input).FormatNodeTree(), result.FormatNodeTree())
+
+	s = &releasingStream{Stream: buffer.ReaderBuffer(strings.NewReader("a=b;c;d=;e;"))}
+	result = p.ParseBuffer(s)
+	a.False(result.Success)
+	a.Equal(6, s.released)
+}
+
+func TestParseReaderMatches(t *testing.T) {
+	a := assert.New(t)
+	parse := func(p *Parser, input string) (*Result, *Result) {
+		result, err := p.ParseReader(strings.NewReader(input))
+		a.NoError(err)
+		return result, p.ParseString(input)
+	}
+
+	p := NewParser(CutStatements)
+	result, expected := parse(p, "abc=de;fg;")
+	a.True(result.Success)
+	a.Equal(expected.Match(), result.Match())
+	a.Equal("abc=de;fg;", result.Match())
+
+	p = NewParser(func(b *Builder) Expression {
+		number := b.Action(b.OneOrMore(b.Range('0', '9')), func(r *Result, _ []interface{}) interface{} {
+			return r.Match()
+		})
+		return b.Sequence(b.ZeroOrMore(number, b.Cut(), b.Rune(';')), b.End())
+	})
+	result, expected = parse(p, "12345;678;")
+	a.True(result.Success)
+	a.Equal([]interface{}{"12345", ";", "678", ";"}, result.Values)
+	a.Equal(expected.Values, result.Values)
+
+	p = NewParser(func(b *Builder) Expression {
+		space := b.NamedRule("Space", func() Expression { return b.ZeroOrMore(b.Rune(' ')) }, b.DropNode())
+		word := b.NamedRule("Word", func() Expression { return b.Sequence(b.OneOrMore(b.Range('a', 'z')), space) })
+		return b.Sequence(word, word, word, b.End())
+	})
+	p.SetConcrete(true)
+	result, expected = parse(p, "ab   cd  ef ")
+	a.True(result.Success)
+	a.Equal(expected.FormatNodeTree(), result.FormatNodeTree())
+	if a.Len(result.Nodes, 3) {
+		a.Equal("   cd", result.Nodes[1].Source())
+	}
+
+	for _, predicate := range []func(b *Builder) Expression{
+		func(b *Builder) Expression { return b.TestNot(b.Rune('a'), b.Cut(), b.Rune('b')) },
+		func(b *Builder) Expression { return b.Choice(b.Test(b.Rune('a'), b.Cut(), b.Rune('b')), b.Empty()) },
+	} {
+		p = NewParser(func(b *Builder) Expression {
+			return b.Sequence(predicate(b), b.Literal("ac"), b.End())
+		})
+		result, expected = parse(p, "ac")
+		a.True(expected.Success)
+		a.Equal(expected.Success, result.Success)
+		a.Equal(expected.End, result.End)
+	}
+}
+
+func TestUTF8Input(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(func(b *Builder) Expression {
+		return b.Sequence(b.Literal("ŧ←"), b.OneOrMore(b.Range('α', 'ω')), b.End())
+	})
+	input := "ŧ←αβγ"
+	result := p.ParseString(input)
+	a.True(result.Success)
+	a.Equal(len(input), result.End)
+	a.Equal("αβγ", result.Results[1].Match())
+
+	result = p.ParseString("ŧ←αβ!")
+	a.False(result.Success)
+	if ffr := result.FarthestFailedResult(); a.NotNil(ffr) {
+		l := ffr.Input.Location(ffr.Start)
+		a.Equal(4, l.Position)
+		a.Equal(9, l.Offset)
+		a.Equal("!", "ŧ←αβ!"[l.Offset:])
+	}
+	a.Equal("Invalid input '!' at position 4 (line 1, column 5), expected END", result.BetterError())
+}
+
+func TestAction(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(func(b *Builder) Expression {
+		space := b.NamedRule("Space", func() Expression { return b.ZeroOrMore(b.Rune(' ')) }, b.DropNode())
+		word := b.Action(b.OneOrMore(b.Range('a', 'z')), func(r *Result, values []interface{}) interface{} {
+			return strings.ToUpper(r.Match())
+		})
+		return b.Action(b.OneOrMore(word, space), func(r *Result, values []interface{}) interface{} {
+			return len(values)
+		})
+	})
+	result := p.ParseString("ab cd  ef")
+	a.True(result.Success)
+	a.Equal(3, result.Value())
+	a.Equal([]interface{}{"AB", "CD", "EF"}, result.Results[0].Values)
+	a.Equal(`"a" "b" "c" "d" "e" "f"`, strings.Replace(result.FormatNodeTree(), "\n", " ", -1))
+	a.Nil(p.ParseString("").Value())
+
+	p = NewParser(func(b *Builder) Expression {
+		return b.OneOrMore(b.Range('a', 'z'), b.Optional(b.Rune(' ')))
+	})
+	result = p.ParseString("ab cd")
+	a.True(result.Success)
+	a.Nil(result.Values, "values must only be produced by parsers having actions")
+}
+
+func TestToken(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(func(b *Builder) Expression {
+		digit := b.NamedRule("Digit", func() Expression { return b.Range('0', '9') })
+		number := b.NamedRule("Number", func() Expression {
+			return b.Token(b.OneOrMore(digit), b.Optional(b.Rune('.'), b.OneOrMore(digit)))
+		})
+		numbers := b.Action(b.Sequence(number, b.ZeroOrMore(b.Rune(','), number)), func(_ *Result, values []interface{}) interface{} {
+			return values
+		})
+		return b.Sequence(numbers, b.End())
+	})
+	result := p.ParseString("123,4.5")
+	a.True(result.Success)
+	a.Equal(`(Number "123") "," (Number "4.5")`, strings.Replace(result.FormatNodeTree(), "\n", " ", -1))
+	a.Equal([]interface{}{"123", ",", "4.5"}, result.Value())
+	token := result.Nodes[2].Children[0]
+	a.Equal(4, token.Start)
+	a.Equal(7, token.End)
+	result = p.ParseString("1,.")
+	a.False(result.Success)
+	a.Contains(result.BetterError(), "expected [0-9]")
+
+	p = NewParser(func(b *Builder) Expression {
+		digits := b.NamedRule("Digits", func() Expression { return b.OneOrMore(b.Range('0', '9')) })
+		return b.Choice(b.Sequence(b.Token(digits), b.Rune('!')), digits)
+	})
+	p.SetMemoize(true)
+	result = p.ParseString("123!")
+	a.True(result.Success)
+	a.Equal(`"123" "!"`, strings.Replace(result.FormatNodeTree(), "\n", " ", -1))
+	a.Empty(result.Results[0].Results[0].Results[0].Nodes, "nodes must not be built inside tokens")
+	result = p.ParseString("123")
+	a.True(result.Success)
+	a.Equal(`(Digits "1" "2" "3")`, result.FormatNodeTree())
+
+	p = NewParser(func(b *Builder) Expression {
+		return b.Token(b.OneOrMore(b.Range('0', '9')))
+	})
+	result, err := p.ParseReader(strings.NewReader("12345"))
+	a.NoError(err)
+	a.True(result.Success)
+	a.Equal("12345", result.Match())
+	a.Equal(`"12345"`, result.FormatNodeTree())
+}
+
+func TestShaping(t *testing.T) {
+	a := assert.New(t)
+	grammar := func(b *Builder) Expression {
+		var sum, power, factor func() Expression
+		number := b.NamedRule("Number", func() Expression { return b.Token(b.OneOrMore(b.Range('0', '9'))) }, b.Shape(0))
+		sum = func() Expression {
+			return b.NamedRule("Sum", func() Expression { return b.Sequence(power(), b.ZeroOrMore(b.AnyOf("+-"), power())) })
+		}
+		power = func() Expression {
+			return b.NamedRule("Power", func() Expression { return b.Sequence(factor(), b.ZeroOrMore(b.Rune('^'), factor())) }, b.Shape(Collapse|FoldRight))
+		}
+		factor = func() Expression {
+			return b.NamedRule("Factor", func() Expression { return b.Choice(number, b.Sequence(b.Rune('('), sum(), b.Rune(')'))) })
+		}
+		return b.Sequence(sum(), b.End())
+	}
+	format := func(r *Result) string { return strings.Replace(r.FormatNodeTree(), "\n", " ", -1) }
+	p := NewParser(grammar)
+	a.Equal(`(Sum (Factor (Number "1")) "-" (Power (Factor (Number "2")) "^" (Factor (Number "3"))))`, format(p.ParseString("1-2^3")))
+	p.SetShaping(Collapse | DropPunctuation | FoldLeft)
+	a.Equal(`(Sum (Sum (Number "1") "-" (Number "2")) "+" (Number "3"))`, format(p.ParseString("1-2+3")))
+	a.Equal(`(Sum (Number "1") "-" (Power (Number "2") "^" (Power (Number "3") "^" (Number "4"))))`, format(p.ParseString("1-2^3^4")))
+	a.Equal(`(Power (Sum (Number "1") "+" (Number "2")) "^" (Number "3"))`, format(p.ParseString("(1+2)^3")))
+	a.Equal(`(Number "7")`, format(p.ParseString("((7))")))
+	result := p.ParseString("1-2+3")
+	a.Equal(0, result.Nodes[0].Children[0].Start)
+	a.Equal(3, result.Nodes[0].Children[0].End)
+	code, err := Generate(p, "shaped", "Shaped")
+	if a.NoError(err) {
+		a.Contains(string(code), ".SetShaping(seared.Collapse | seared.FoldRight)")
+		a.Contains(string(code), ".SetShaping(seared.Collapse | seared.DropPunctuation | seared.FoldLeft)")
+	}
+
+	p.SetConcrete(true)
+	result = p.ParseString("((1+2)^3)")
+	a.Equal(`(Power (Sum (Number {(~Factor "(") (~Factor "(") "1"}) "+" (Number "2")) {(~Factor ")") "^"} (Number {"3" (~Factor ")")}))`, format(result))
+	if a.Len(result.Nodes, 1) {
+		a.Equal("((1+2)^3)", result.Nodes[0].Source())
+		parsed, err := node.Parse(result.Nodes[0].FormatSpans())
+		if a.NoError(err) {
+			a.Equal(result.Nodes[0], parsed)
+			a.Equal("((1+2)^3)", parsed.Source())
+		}
+	}
+
+	p = NewParser(func(b *Builder) Expression {
+		space := b.NamedRule("Space", func() Expression { return b.ZeroOrMore(b.Rune(' ')) }, b.DropNode())
+		name := b.NamedRule("Name", func() Expression { return b.Token(b.OneOrMore(b.Range('a', 'z'))) })
+		return b.NamedRule("Let", func() Expression {
+			return b.Sequence(b.Literal("let"), space, name, space, b.Rune('='), space, name, b.Rune(';'), b.End())
+		})
+	})
+	p.SetShaping(FoldLeft)
+	a.Equal(`(Let "let" (Name "x") "=" (Name "y") ";")`, format(p.ParseString("let x = y;")))
+}
+
+func TestGenerateUnsupported(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(SyncStatements)
+	_, err := Generate(p, "statements", "Statements")
+	a.EqualError(err, `generating Statements: synchronization of rule "SyncStatement" is not supported`)
+
+	p = NewParser(CutStatements)
+	p.SetConcrete(true)
+	_, err = Generate(p, "statements", "Statements")
+	a.EqualError(err, "generating Statements: concrete mode is not supported")
+	p.SetConcrete(false)
+	_, err = Generate(p, "statements", "Statements")
+	a.NoError(err)
+}
+
+func TestNullableRepetitions(t *testing.T) {
+	a := assert.New(t)
+	_, err := BuildNamedParser("Loops", func(b *Builder) Expression {
+		space := b.NamedRule("Space", func() Expression { return b.ZeroOrMore(b.Rune(' ')) })
+		item := b.NamedRule("Item", func() Expression {
+			return b.Sequence(b.OneOrMore(space), b.Token(b.Optional(b.Rune('x'))))
+		})
+		items := b.NamedRule("Items", func() Expression { return b.ZeroOrMore(b.Optional(item)) })
+		return b.Sequence(items, b.ZeroOrMore(b.Rune(';'), b.Optional(space)), b.End())
+	})
+	if a.Error(err) {
+		errs, ok := err.(GrammarErrors)
+		if a.True(ok) && a.Len(errs, 2) {
+			a.Equal([]string{"Loops", "Items"}, errs[0].Path)
+			a.Equal("Item?*", errs[0].Expression)
+			a.Equal([]string{"Loops", "Items", "Item"}, errs[1].Path)
+		}
+		a.Equal("repeated expression may match empty input in Item?* at Loops > Items; repeated expression may match empty input in Space+ at Loops > Items > Item", err.Error())
+	}
+	a.Panics(func() {
+		NewParser(func(b *Builder) Expression { return b.OneOrMore(b.Test(b.Rune('a'))) })
+	})
+	p, err := BuildParser(func(b *Builder) Expression { return b.ZeroOrMore(b.Rune('a')) })
+	if a.NoError(err) {
+		a.Equal("TestNullableRepetitions", p.Name())
+	}
+}
+
+func TestNullableRecoveries(t *testing.T) {
+	a := assert.New(t)
+	_, err := BuildNamedParser("Statements", func(b *Builder) Expression {
+		statement := b.NamedRule("Statement", func() Expression {
+			return b.Sequence(CutWord(b), b.Rune(';'))
+		}, b.Synchronize(b.ZeroOrMore(b.Optional(b.Rune(';')))))
+		return b.Sequence(b.ZeroOrMore(statement), b.End())
+	})
+	if a.Error(err) {
+		errs, ok := err.(GrammarErrors)
+		if a.True(ok) && a.Len(errs, 1) {
+			a.Equal(NullableRepetition, errs[0].Defect)
+			a.Equal([]string{"Statements", "Statement"}, errs[0].Path)
+			a.Equal("';'?*", errs[0].Expression)
+		}
+	}
+	p := NewParser(LabeledStatements)
+	err = p.SetRecovery("MissingValue", func(b *Builder) Expression {
+		return b.ZeroOrMore(b.Optional(b.Rune(';')))
+	})
+	if a.Error(err) {
+		errs, ok := err.(GrammarErrors)
+		if a.True(ok) && a.Len(errs, 1) {
+			a.Equal([]string{"recovery MissingValue"}, errs[0].Path)
+		}
+	}
+	result := p.ParseString("a=;")
+	a.False(result.Success)
+	a.Empty(result.Errors)
+	a.NoError(p.SetRecovery("MissingValue", func(b *Builder) Expression {
+		return b.ZeroOrMore(b.TestNot(b.Rune(';')), b.Any())
+	}))
+
+	p, err = BuildParser(func(b *Builder) Expression {
+		return b.Sequence(b.ZeroOrMore(b.Choice(b.Range('a', 'z'), b.Throw("E"))), b.End())
+	})
+	a.NoError(err)
+	err = p.SetRecovery("E", func(b *Builder) Expression { return b.ZeroOrMore(b.Rune('!')) })
+	if a.Error(err) {
+		errs, ok := err.(GrammarErrors)
+		if a.True(ok) && a.Len(errs, 1) {
+			a.Equal([]string{"TestNullableRecoveries"}, errs[0].Path)
+			a.Equal("[a-z]/%{E}*", errs[0].Expression)
+		}
+	}
+	result = p.ParseString("ab1")
+	a.False(result.Success)
+	a.NoError(p.SetRecovery("E", func(b *Builder) Expression { return b.OneOrMore(b.Rune('!')) }))
+	result = p.ParseString("ab!c1")
+	a.False(result.Success)
+	a.Len(result.Errors, 1)
+}
+
+func TestAnalyze(t *testing.T) {
+	a := assert.New(t)
+	p := NewNamedParser("Defects", func(b *Builder) Expression {
+		var sum, term func() Expression
+		sum = func() Expression {
+			return b.NamedRule("Sum", func() Expression { return b.Choice(b.Sequence(term(), b.Rune('+'), term()), term()) })
+		}
+		term = func() Expression {
+			return b.NamedRule("Term", func() Expression {
+				return b.Choice(b.Sequence(sum(), b.Rune('*'), sum()), b.Range('0', '9'), b.Rune('7'))
+			})
+		}
+		b.NamedRule("Unused", func() Expression { return b.Rune('u') })
+		keyword := b.NamedRule("Keyword", func() Expression {
+			return b.Choice(b.Literal("in"), b.Literal("int"), b.Sequence(b.Rune('i'), b.Rune('f')), b.Literal("i"))
+		})
+		rest := b.Choice(b.Any(), b.Literal("ab"), b.Optional(b.Rune('x')), b.End(), b.Rune('z'))
+		return b.Sequence(sum(), keyword, rest)
+	})
+	errs := p.Analyze()
+	if a.Len(errs, 7) {
+		a.Equal(LeftRecursion, errs[0].Defect)
+		a.Equal([]string{"Sum", "Term", "Sum"}, errs[0].Path)
+		a.Equal(UnreachableRule, errs[1].Defect)
+		a.Equal("unreachable rule at Unused", errs[1].Error())
+		a.Equal(ShadowedAlternative, errs[2].Defect)
+		a.Equal([]string{"Defects", "Sum", "Term"}, errs[2].Path)
+		a.Equal(`alternative '7' is shadowed by [0-9] in Sum '*' Sum/[0-9]/'7' at Defects > Sum > Term`, errs[2].Error())
+		a.Equal(`alternative 'int' is shadowed by 'in'`, errs[3].Problem)
+		a.Equal(`alternative 'ab' is shadowed by .`, errs[4].Problem)
+		a.Equal(`alternative END is shadowed by 'x'?`, errs[5].Problem)
+		a.Equal(`alternative 'z' is shadowed by .`, errs[6].Problem)
+	}
+	a.Empty(NewParser(CutStatements).Analyze())
+	p = NewNamedParser("Synchronized", func(b *Builder) Expression {
+		skip := b.NamedRule("Skip", func() Expression { return b.ZeroOrMore(b.TestNot(b.Rune(';')), b.Any()) })
+		statement := b.NamedRule("Statement", func() Expression {
+			return b.Sequence(CutWord(b), b.Rune(';'))
+		}, b.Synchronize(b.Sequence(skip, b.Choice(b.Any(), b.Rune(';')))))
+		return b.Sequence(b.ZeroOrMore(statement), b.End())
+	})
+	errs = p.Analyze()
+	if a.Len(errs, 1) {
+		a.Equal(ShadowedAlternative, errs[0].Defect)
+		a.Equal([]string{"Synchronized", "Statement"}, errs[0].Path)
+		a.Equal(`alternative ';' is shadowed by .`, errs[0].Problem)
+	}
+}
+
+func TestFirst(t *testing.T) {
+	a := assert.New(t)
+	var number, sum, list, optional Expression
+	p := NewParser(func(b *Builder) Expression {
+		number = b.NamedRule("Number", func() Expression { return b.Token(b.Optional(b.Rune('-')), b.OneOrMore(b.Range('0', '9'))) })
+		sum = b.NamedRule("Sum", func() Expression { return b.Sequence(number, b.ZeroOrMore(b.AnyOf("+-"), number)) })
+		list = b.Sequence(b.Literal("let"), b.ZeroOrMore(b.Choice(sum, b.Literal("x")), b.Optional(b.Rune(','))), b.End())
+		optional = b.Optional(b.AnyOf("ba"))
+		return list
+	})
+	cs, empty := First(number)
+	a.Equal(CharSet{{'-', '-'}, {'0', '9'}}, cs)
+	a.False(empty)
+	a.True(cs.Contains('5'))
+	a.False(cs.Contains('+'))
+	a.Equal(`['-' '0'-'9']`, cs.String())
+	cs, _ = First(list)
+	a.Equal(CharSet{{'l', 'l'}}, cs)
+	cs, empty = First(optional)
+	a.Equal(CharSet{{'a', 'b'}}, cs)
+	a.True(empty)
+	cs, end := p.Follow(number)
+	a.Equal(`['+'-'-' '0'-'9' 'x']`, cs.String())
+	a.True(end)
+	cs, end = p.Follow(sum)
+	a.Equal(`[','-'-' '0'-'9' 'x']`, cs.String())
+	a.True(end)
+}
+
+// counter counts the rules tried by a parser in debug mode
+type counter struct {
+	tried int
+}
+
+func (c *counter) Debugf(format string, args ...interface{}) {
+	if strings.HasPrefix(format, "Trying") {
+		c.tried++
+	}
+}
+
+func Keywords(b *Builder) Expression {
+	return b.Rule(func() Expression {
+		keywords := []Expression{}
+		for _, k := range []string{"break", "case", "chan", "const", "continue", "default", "defer", "else", "for", "func", "go", "goto", "if", "import"} {
+			k := k
+			keywords = append(keywords, b.NamedRule("Keyword_"+k, func() Expression { return b.Literal(k) }))
+		}
+		space := b.NamedRule("Space", func() Expression { return b.OneOrMore(b.Rune(' ')) }, b.DropNode())
+		keyword := b.NamedRule("Keyword", func() Expression { return b.Choice(keywords...) })
+		return b.Sequence(keyword, b.ZeroOrMore(space, keyword), b.End())
+	})
+}
+
+func TestPredictiveChoice(t *testing.T) {
+	a := assert.New(t)
+	fast, slow := NewParser(Keywords), NewParser(Keywords)
+	slow.SetPredictive(false)
+	failures := func(r *Result) []string {
+		ss := []string{}
+		for _, f := range r.FailedChildlessResults() {
+			ss = append(ss, strconv.Itoa(f.Start)+" "+f.Expression.Expectation())
+		}
+		return ss
+	}
+	for _, input := range []string{"if else for", "const continue", "import x", "fo", "", "go  gox"} {
+		result, expected := fast.ParseString(input), slow.ParseString(input)
+		a.Equal(expected.Success, result.Success, input)
+		a.Equal(expected.FormatNodeTree(), result.FormatNodeTree(), input)
+		a.Equal(expected.BetterError(), result.BetterError(), input)
+		a.Equal(failures(expected), failures(result), input)
+	}
+	result := fast.ParseString("x")
+	a.Contains(result.FormatResultTree(), "\n        Skipped: Invalid input 'x' at position 0 (line 1, column 1), expected 'break' or 'case' or ")
+	a.Len(result.FailedChildlessResults(), 14)
+	result = fast.ParseString("do")
+	a.Contains(result.FormatResultTree(), "\n        Skipped: Invalid input 'd' at position 0 (line 1, column 1), expected 'break' or 'case' or 'chan' or 'const' or 'continue'\n        Keyword_default: ")
+	tried := func(p *Parser) int {
+		c := &counter{}
+		p.SetLog(c)
+		p.SetDebug(true)
+		a.True(p.ParseString("if else for").Success)
+		return c.tried
+	}
+	a.Equal(10, tried(fast))
+	a.Equal(37, tried(slow))
+	fast.SetRecoveryMode(true)
+	a.Equal(37, tried(fast))
+}
+
+func TestGrammar(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(Keywords)
+	a.NoError(p.SetRecovery("missing", func(b *Builder) Expression {
+		return b.NamedRule("Skip", func() Expression { return b.ZeroOrMore(b.TestNot(b.Rune(' ')), b.Any()) })
+	}))
+	g := p.Grammar()
+	a.Equal("TestGrammar", g.Name)
+	a.Equal(grammar.Reference, g.Main.Operator)
+	a.Equal("Keywords", g.Main.Rule.Name)
+	a.Len(g.Rules, 18)
+	a.Equal("Skip", g.Rules[17].Name)
+	keyword := g.Rule("Keyword")
+	if a.NotNil(keyword) {
+		a.Equal(grammar.Choice, keyword.Expression.Operator)
+		a.Len(keyword.Expression.Operands, 14)
+		a.Equal("Keyword_break", keyword.Expression.Operands[0].Rule.Name)
+		a.Equal(grammar.Literal, keyword.Expression.Operands[0].Rule.Expression.Operator)
+		a.Equal("break", keyword.Expression.Operands[0].Rule.Expression.Text)
+	}
+	a.True(g.Rule("Space").DropNode)
+	a.Nil(g.Rule("Missing"))
+	operators := []string{}
+	grammar.Inspect(g.Recoveries["missing"].Rule.Expression, func(e *grammar.Expression) bool {
+		operators = append(operators, e.Operator.String()+" "+e.Expectation)
+		return true
+	})
+	a.Equal([]string{"ZeroOrMore (!' ' .)*", "Sequence !' ' .", "TestNot !' '", "Rune ' '", "Any ."}, operators)
+	custom := NewParser(func(b *Builder) Expression { return b.Sequence(ruleM(nil), b.Range('a', 'z')) }).Grammar()
+	a.Equal(grammar.Custom, custom.Main.Operands[0].Operator)
+	a.Equal(grammar.Range, custom.Main.Operands[1].Operator)
+	a.Equal('a', custom.Main.Operands[1].Low)
+	a.Equal('z', custom.Main.Operands[1].High)
+}
+
+func TestNodeSpans(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser(CutStatements)
+	result := p.ParseString("ab=c;d;")
+	a.True(result.Success)
+	statements := result.Nodes[0]
+	a.Equal(0, statements.Start)
+	a.Equal(7, statements.End)
+	second := statements.Children[1]
+	a.Equal("CutStatement", second.Label)
+	a.Equal(5, second.Start)
+	a.Equal(7, second.End)
+	a.Equal(`"="`, statements.Children[0].Children[1].Format())
+	a.Equal(2, statements.Children[0].Children[1].Start)
+	a.Equal(3, statements.Children[0].Children[1].End)
+	start, end := second.Span(result.Input)
+	a.Equal("position 5 (line 1, column 6)", start.String())
+	a.Equal("position 7 (line 1, column 8)", end.String())
+}
@@ -0,0 +1,375 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package seared
+
+import "github.com/lalloni/seared/buffer"
+
+// session wraps the input buffer of a single parse carrying the state that
+// must live exactly as long as the parse does.
+type session struct {
+	buffer.Buffer
+	parser *Parser
+	memo   map[int]map[*rule]*memoEntry
+	pruned int
+	heads  map[int]*head
+	stack  *leftRecursion
+	frames []frame
+	errors []*SyntaxError
+	// farthest is the farthest position a failure was found at
+	farthest int
+	// failures are the positions of the syntax errors found by previous
+	// attempts to parse the input in recovery mode
+	failures map[int]bool
+	// stream is the input when it can release what the parse will not
+	// backtrack to
+	stream buffer.Stream
+	// tokens is the number of tokens being applied, whose inner expressions
+	// produce neither nodes nor values
+	tokens int
+}
+
+// memoEntry holds the result of a rule application or, while the rule is
+// still being evaluated, the left recursion detection state.
+type memoEntry struct {
+	result *Result
+	lr     *leftRecursion
+}
+
+// leftRecursion is the seed being grown for a left recursive rule
+// application, chained into a stack of the rule applications in progress.
+type leftRecursion struct {
+	seed *Result
+	rule *rule
+	head *head
+	next *leftRecursion
+}
+
+// head is the rule application at which a left recursion cycle starts along
+// with the rules involved in that cycle.
+type head struct {
+	rule     *rule
+	involved map[*rule]bool
+	eval     map[*rule]bool
+}
+
+func newSession(p *Parser, input buffer.Buffer, failures map[int]bool) *session {
+	s := &session{
+		Buffer:   input,
+		parser:   p,
+		memo:     map[int]map[*rule]*memoEntry{},
+		heads:    map[int]*head{},
+		farthest: -1,
+		failures: failures,
+	}
+	if stream, ok := input.(buffer.Stream); ok && !p.recovering {
+		s.stream = stream
+	}
+	return s
+}
+
+func (s *session) reached(pos int) {
+	if pos > s.farthest {
+		s.farthest = pos
+	}
+}
+
+// memoizes tells whether the results of r are memoized, which is not done
+// inside tokens as they lack nodes and values.
+func (s *session) memoizes(r *rule) bool {
+	return s.parser.memoize && r.memoize && s.tokens == 0
+}
+
+// inToken tells whether the expressions applied to input are inside a token,
+// so they need to produce neither nodes nor values.
+func inToken(input buffer.Buffer) bool {
+	s, ok := input.(*session)
+	return ok && s.tokens > 0
+}
+
+func (s *session) recalled(r *rule, pos int) *memoEntry {
+	return s.memo[pos][r]
+}
+
+func (s *session) remember(r *rule, pos int, m *memoEntry) {
+	if pos < s.pruned {
+		return
+	}
+	rs, ok := s.memo[pos]
+	if !ok {
+		rs = map[*rule]*memoEntry{}
+		s.memo[pos] = rs
+	}
+	rs[r] = m
+}
+
+func (s *session) forget(r *rule, pos int) {
+	if rs, ok := s.memo[pos]; ok {
+		delete(rs, r)
+		if len(rs) == 0 {
+			delete(s.memo, pos)
+		}
+	}
+}
+
+// applyRule applies r at pos, recalling its memoized result if any.
+func (s *session) applyRule(r *rule, pos int) *Result {
+	if r.leftRecursive {
+		return s.growRule(r, pos)
+	}
+	if !s.memoizes(r) {
+		return r.apply(s, pos)
+	}
+	if m := s.recalled(r, pos); m != nil {
+		if s.parser.debug {
+			s.parser.log.Debugf("Recalled %q at %s of %q", r.Name(), s.Location(pos), s.Input())
+		}
+		s.reached(m.result.farthest)
+		return m.result
+	}
+	result := r.apply(s, pos)
+	s.remember(r, pos, &memoEntry{result: result})
+	return result
+}
+
+// growRule implements the left recursion supporting rule application of
+// "Packrat Parsers Can Support Left Recursion" by Warth, Douglass & Millstein.
+// Results are kept in the memo table only while needed for growing seeds
+// unless the rule is memoized. As there, the rules involved in the left
+// recursion of another one do not grow their own seeds while it grows.
+func (s *session) growRule(r *rule, pos int) *Result {
+	m := s.recall(r, pos)
+	if m == nil {
+		lr := &leftRecursion{seed: Failure(r, s, pos, pos), rule: r, next: s.stack}
+		s.stack = lr
+		m = &memoEntry{lr: lr}
+		s.remember(r, pos, m)
+		result := r.apply(s, pos)
+		s.stack = lr.next
+		if lr.head != nil {
+			lr.seed = result
+			return s.answer(r, pos, m)
+		}
+		m.lr = nil
+		m.result = result
+		if !s.memoizes(r) {
+			s.forget(r, pos)
+		}
+		return result
+	}
+	if m.lr != nil {
+		s.setup(r, m.lr)
+		s.reached(m.lr.seed.farthest)
+		return m.lr.seed
+	}
+	if s.parser.debug {
+		s.parser.log.Debugf("Recalled %q at %s of %q", r.Name(), s.Location(pos), s.Input())
+	}
+	s.reached(m.result.farthest)
+	return m.result
+}
+
+func (s *session) recall(r *rule, pos int) *memoEntry {
+	m := s.recalled(r, pos)
+	h := s.heads[pos]
+	if h == nil || !h.eval[r] {
+		return m
+	}
+	delete(h.eval, r)
+	// the entry holds the current seed while r is applied, so applying it
+	// again at pos during this growth step recalls that
+	if m == nil {
+		m = &memoEntry{result: Failure(r, s, pos, pos)}
+		s.remember(r, pos, m)
+	} else if m.lr != nil {
+		m.result = m.lr.seed
+	}
+	m.lr = nil
+	m.result = r.apply(s, pos)
+	return m
+}
+
+func (s *session) setup(r *rule, lr *leftRecursion) {
+	if lr.head == nil {
+		lr.head = &head{rule: r, involved: map[*rule]bool{}}
+	}
+	for l := s.stack; l != nil && l.head != lr.head; l = l.next {
+		l.head = lr.head
+		lr.head.involved[l.rule] = true
+	}
+}
+
+func (s *session) answer(r *rule, pos int, m *memoEntry) *Result {
+	h := m.lr.head
+	if h.rule != r {
+		return m.lr.seed
+	}
+	m.result = m.lr.seed
+	m.lr = nil
+	if m.result.Success {
+		s.grow(r, pos, m, h)
+	}
+	for rr := range h.involved {
+		if !s.memoizes(rr) {
+			s.forget(rr, pos)
+		}
+	}
+	if !s.memoizes(r) {
+		s.forget(r, pos)
+	}
+	return m.result
+}
+
+func (s *session) grow(r *rule, pos int, m *memoEntry, h *head) {
+	if s.parser.cuts || s.stream != nil {
+		s.enter(pos, false)
+		defer s.leave()
+	}
+	s.heads[pos] = h
+	for {
+		h.eval = map[*rule]bool{}
+		for rr := range h.involved {
+			h.eval[rr] = true
+		}
+		result := r.apply(s, pos)
+		if !result.Success || result.End <= m.result.End {
+			break
+		}
+		m.result = result
+	}
+	delete(s.heads, pos)
+}
+
+// frame is a point to which the parse may backtrack while the expression that
+// entered it is being applied, unless committed by a cut. Boundary frames are
+// entered by rules and predicates to keep cuts from escaping them. Pinned frames are entered
+// by the expressions reading the input they match, which is kept from their
+// position on like the one of rules.
+type frame struct {
+	position  int
+	committed bool
+	boundary  bool
+	pinned    bool
+}
+
+// tracking returns the session of the input when backtracking frames must be
+// tracked for it.
+func tracking(input buffer.Buffer) *session {
+	if s, ok := input.(*session); ok && (s.parser.cuts || s.stream != nil) {
+		return s
+	}
+	return nil
+}
+
+// streaming returns the session of the input when it releases the input the
+// parse will not read anymore.
+func streaming(input buffer.Buffer) *session {
+	if s, ok := input.(*session); ok && s.stream != nil {
+		return s
+	}
+	return nil
+}
+
+func (s *session) enter(pos int, boundary bool) {
+	s.frames = append(s.frames, frame{position: pos, boundary: boundary})
+}
+
+// pin keeps the input from pos on until leaving.
+func (s *session) pin(pos int) {
+	s.frames = append(s.frames, frame{position: pos, pinned: true})
+}
+
+func (s *session) advance(pos int) {
+	s.frames[len(s.frames)-1].position = pos
+	if s.stream != nil {
+		s.stream.Release(s.retained(pos))
+	}
+}
+
+func (s *session) leave() {
+	s.frames = s.frames[:len(s.frames)-1]
+}
+
+// cut commits the innermost backtracking frame and discards the memoized
+// results of the positions no longer reachable by backtracking.
+func (s *session) cut(pos int) {
+	for i := len(s.frames) - 1; i >= 0; i-- {
+		if f := &s.frames[i]; !f.pinned {
+			if !f.boundary {
+				f.committed = true
+			}
+			break
+		}
+	}
+	low := s.lowest(pos)
+	for ; s.pruned < low; s.pruned++ {
+		delete(s.memo, s.pruned)
+	}
+	if s.stream != nil {
+		s.stream.Release(s.retained(pos))
+	}
+}
+
+// lowest returns the lowest position the parse may backtrack to, which is at
+// most pos.
+func (s *session) lowest(pos int) int {
+	low := pos
+	for _, f := range s.frames {
+		if !f.boundary && !f.pinned && !f.committed && f.position < low {
+			low = f.position
+		}
+	}
+	return low
+}
+
+// retained returns the lowest position of the input the parse may still
+// read, either backtracking to it or reading the text matched by the rules
+// and the pinning expressions being applied, which is at most pos.
+func (s *session) retained(pos int) int {
+	low := s.lowest(pos)
+	for _, f := range s.frames {
+		if (f.boundary || f.pinned) && f.position < low {
+			low = f.position
+		}
+	}
+	return low
+}
+
+// report records a syntax error the parse recovered from, once per position
+// and label.
+func (s *session) report(e *SyntaxError) {
+	for _, r := range s.errors {
+		if r.Label == e.Label && r.position == e.position {
+			return
+		}
+	}
+	i := len(s.errors)
+	for i > 0 && s.errors[i-1].position > e.position {
+		i--
+	}
+	s.errors = append(s.errors, nil)
+	copy(s.errors[i+1:], s.errors[i:])
+	s.errors[i] = e
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package seared
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/lalloni/seared/node"
+)
+
+// Shaping is a set of policies turning the parse tree nodes of rules into
+// the nodes of an abstract syntax tree.
+type Shaping int
+
+const (
+	// Collapse replaces the node of a rule having exactly one child by the
+	// child.
+	Collapse Shaping = 1 << iota
+	// DropPunctuation drops the terminal children of the node of a rule made
+	// only of brackets, quotes or any of ",;:.", which are kept as trivia in
+	// concrete mode.
+	DropPunctuation
+	// FoldLeft turns the node of a rule with children X op X op X, as
+	// produced by X (op X)* where the operators are terminals and the
+	// operands are not, into left associative nodes of the rule with children
+	// X op X.
+	FoldLeft
+	// FoldRight is like FoldLeft but makes the nodes right associative.
+	FoldRight
+)
+
+var shapings = []struct {
+	shaping Shaping
+	name    string
+}{
+	{Collapse, "Collapse"},
+	{DropPunctuation, "DropPunctuation"},
+	{FoldLeft, "FoldLeft"},
+	{FoldRight, "FoldRight"},
+}
+
+func (s Shaping) String() string {
+	ss := []string{}
+	for _, p := range shapings {
+		if s&p.shaping != 0 {
+			ss = append(ss, p.name)
+		}
+	}
+	if len(ss) == 0 {
+		return "0"
+	}
+	return strings.Join(ss, "|")
+}
+
+// shapes returns the shaping of the rule or, when not set, the one of its
+// parser.
+func (r *rule) shapes() Shaping {
+	if r.shaped || r.parser == nil {
+		return r.shaping
+	}
+	return r.parser.shaping
+}
+
+// shape returns the nodes standing for the match of the rule from start to end
+// producing the children nodes. Trivia nodes are kept in place and do not
+// count as children.
+func (r *rule) shape(children []*node.Node, start, end int) []*node.Node {
+	s := r.shapes()
+	if s&DropPunctuation != 0 {
+		kept := []*node.Node{}
+		for _, c := range children {
+			switch {
+			case c.Kind != node.Terminal || !punctuation(c.Value):
+				kept = append(kept, c)
+			case r.parser != nil && r.parser.concrete:
+				kept = append(kept, node.NewTrivia(r.Name(), c.Value).WithSpan(c.Start, c.End))
+			}
+		}
+		children = kept
+	}
+	if s&(FoldLeft|FoldRight) != 0 && operation(children) {
+		for is := significant(children); len(is) > 3; is = significant(children) {
+			first, last := is[0], is[2]
+			if s&FoldLeft == 0 {
+				first, last = is[len(is)-3], is[len(is)-1]
+			}
+			folded := node.NewNonTerminal(r.Name(), append([]*node.Node{}, children[first:last+1]...)).WithSpan(children[first].Start, children[last].End)
+			children = append(append(append([]*node.Node{}, children[:first]...), folded), children[last+1:]...)
+		}
+	}
+	if s&Collapse != 0 && len(significant(children)) == 1 {
+		return children
+	}
+	return []*node.Node{node.NewNonTerminal(r.Name(), children).WithSpan(start, end)}
+}
+
+// significant returns the indexes of the children which are not trivia.
+func significant(children []*node.Node) []int {
+	is := []int{}
+	for i, c := range children {
+		if c.Kind != node.Trivia {
+			is = append(is, i)
+		}
+	}
+	return is
+}
+
+// operation tells whether the children are X op X op X, with at least two
+// operators being terminals between operands which are not.
+func operation(children []*node.Node) bool {
+	is := significant(children)
+	if len(is) <= 3 || len(is)%2 == 0 {
+		return false
+	}
+	for i, j := range is {
+		if (children[j].Kind == node.Terminal) != (i%2 == 1) {
+			return false
+		}
+	}
+	return true
+}
+
+func punctuation(s string) bool {
+	for _, c := range s {
+		if !unicode.In(c, unicode.Ps, unicode.Pe, unicode.Pi, unicode.Pf) && !strings.ContainsRune(",;:.", c) {
+			return false
+		}
+	}
+	return s != ""
+}
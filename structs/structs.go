@@ -0,0 +1,352 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package structs derives grammars from annotated Go struct definitions, so
+// grammar and syntax tree live in one place, for example:
+//
+//	type Assignment struct {
+//		Name  string   `peg:"[a-z]+"`
+//		_     struct{} `peg:"'='"`
+//		Value Value    `peg:""`
+//	}
+//
+//	type Value struct {
+//		structs.Choice
+//		Number *int  `peg:"[0-9]+"`
+//		Call   *Call `peg:""`
+//	}
+//
+// Every struct type is a rule named after the type matching its fields tagged
+// with peg in sequence, or the first of them matching when it embeds Choice.
+// Fields of struct types, pointers or slices of them match the rule of the
+// struct type unless their tag has an expression, which is written in PEG
+// notation and can refer to those rules by type name. Fields of other types
+// are set from the text matched by the expression of their tag, as done by
+// node.Unmarshal, and blank fields just match it. Pointer fields are optional
+// and slice fields match zero or more times.
+package structs
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+
+	"github.com/lalloni/seared"
+	"github.com/lalloni/seared/node"
+	"github.com/lalloni/seared/peg"
+	"github.com/lalloni/seared/typed"
+)
+
+// Choice is embedded in struct types whose fields are alternatives, which
+// must be pointers.
+type Choice struct{}
+
+// Option is an option for deriving a grammar.
+type Option func(*deriver)
+
+// Whitespace sets the expression written in PEG notation of the input
+// skipped before every field and at the end of input.
+func Whitespace(expression string) Option {
+	return func(d *deriver) {
+		d.whitespace = expression
+	}
+}
+
+// NewParser returns a parser of the grammar derived from the struct type T,
+// whose values are filled with what was matched by their fields, failing to
+// parse with a typed.Error.
+func NewParser[T any](options ...Option) (*typed.Parser[*T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	d := &deriver{types: map[string]reflect.Type{}}
+	for _, o := range options {
+		o(d)
+	}
+	if err := d.collect(t); err != nil {
+		return nil, err
+	}
+	p, err := seared.BuildNamedParser(t.Name(), func(b *seared.Builder) seared.Expression {
+		d.b = b
+		if d.whitespace != "" {
+			d.skip = b.NamedRule("Whitespace", func() seared.Expression {
+				return d.compile(d.whitespace, "whitespace")
+			}, b.DropNode())
+			return b.Sequence(d.rule(t), d.skip, b.End())
+		}
+		return b.Sequence(d.rule(t), b.End())
+	})
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return typed.Wrap(p, func(result *seared.Result) (*T, error) {
+		v := new(T)
+		if err := fill(result.Nodes[0], reflect.ValueOf(v).Elem()); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}), nil
+}
+
+var (
+	choiceType          = reflect.TypeOf(Choice{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+type shape int
+
+const (
+	one shape = iota
+	optional
+	many
+)
+
+// field describes a field matching a grammar element
+type field struct {
+	reflect.StructField
+	// blank tells whether the field only matches its expression
+	blank bool
+	// element is the type of the values matched and shape how many are
+	element reflect.Type
+	shape   shape
+}
+
+// label returns the label of the nodes matched by f in the rule of t
+func (f field) label(t reflect.Type) string {
+	return t.Name() + "." + f.Name
+}
+
+// structured tells whether f matches values of a struct type
+func (f field) structured() bool {
+	return f.element.Kind() == reflect.Struct && !reflect.PtrTo(f.element).Implements(textUnmarshalerType)
+}
+
+// fields returns the fields of the struct type t matching grammar elements
+// and whether t embeds Choice.
+func fields(t reflect.Type) (fs []field, choice bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type == choiceType {
+			choice = true
+			continue
+		}
+		if _, ok := f.Tag.Lookup("peg"); !ok || (f.PkgPath != "" && f.Name != "_") {
+			continue
+		}
+		fd := field{StructField: f, blank: f.Name == "_", element: f.Type}
+		if reflect.PtrTo(f.Type).Implements(textUnmarshalerType) {
+			fs = append(fs, fd)
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.Ptr:
+			fd.element, fd.shape = f.Type.Elem(), optional
+		case reflect.Slice:
+			fd.element, fd.shape = f.Type.Elem(), many
+			if fd.element.Kind() == reflect.Ptr {
+				fd.element = fd.element.Elem()
+			}
+		}
+		fs = append(fs, fd)
+	}
+	return
+}
+
+// text tells whether values of t are set from the text matched
+func text(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+type deriver struct {
+	b          *seared.Builder
+	types      map[string]reflect.Type
+	whitespace string
+	skip       seared.Expression
+	err        error
+}
+
+// collect registers the struct types matched by the fields of t, checking
+// they are supported.
+func (d *deriver) collect(t reflect.Type) error {
+	if t.Kind() != reflect.Struct || t.Name() == "" {
+		return fmt.Errorf("structs: %s is not a named struct type", t)
+	}
+	if r, ok := d.types[t.Name()]; ok {
+		if r != t {
+			return fmt.Errorf("structs: types %s and %s have the same name", r, t)
+		}
+		return nil
+	}
+	d.types[t.Name()] = t
+	fs, choice := fields(t)
+	for _, f := range fs {
+		switch {
+		case choice && f.shape != optional:
+			return fmt.Errorf("structs: alternative %s.%s must be a pointer", t.Name(), f.Name)
+		case f.blank:
+		case f.structured():
+			if err := d.collect(f.element); err != nil {
+				return err
+			}
+		case !text(f.element):
+			return fmt.Errorf("structs: field %s.%s has unsupported type %s", t.Name(), f.Name, f.Type)
+		case f.Tag.Get("peg") == "":
+			return fmt.Errorf("structs: field %s.%s needs an expression", t.Name(), f.Name)
+		}
+	}
+	return nil
+}
+
+// compile returns the expression written in PEG notation in source
+func (d *deriver) compile(source, context string) seared.Expression {
+	e, err := peg.Compile(d.b, source, func(name string) seared.Expression {
+		if t, ok := d.types[name]; ok {
+			return d.rule(t)
+		}
+		return nil
+	})
+	if err != nil {
+		if d.err == nil {
+			d.err = fmt.Errorf("structs: %s: %v", context, err)
+		}
+		return d.b.Empty()
+	}
+	return e
+}
+
+// rule returns the rule of the struct type t
+func (d *deriver) rule(t reflect.Type) seared.Expression {
+	b := d.b
+	return b.NamedRule(t.Name(), func() seared.Expression {
+		fs, choice := fields(t)
+		es := []seared.Expression{}
+		for _, f := range fs {
+			if d.skip != nil && !choice {
+				es = append(es, d.skip)
+			}
+			es = append(es, d.field(t, f, choice))
+		}
+		switch {
+		case len(es) == 0:
+			return b.Empty()
+		case len(es) == 1:
+			return es[0]
+		case choice:
+			return b.Choice(es...)
+		}
+		return b.Sequence(es...)
+	})
+}
+
+// field returns the expression matching the field f of the struct type t
+func (d *deriver) field(t reflect.Type, f field, choice bool) seared.Expression {
+	b := d.b
+	tag := f.Tag.Get("peg")
+	if f.blank {
+		return d.compile(tag, "field "+f.label(t))
+	}
+	e := b.NamedRule(f.label(t), func() seared.Expression {
+		if tag == "" {
+			return d.rule(f.element)
+		}
+		return d.compile(tag, "field "+f.label(t))
+	})
+	switch {
+	case choice:
+	case f.shape == optional:
+		e = b.Optional(e)
+	case f.shape == many && d.skip != nil:
+		e = b.ZeroOrMore(e, d.skip)
+	case f.shape == many:
+		e = b.ZeroOrMore(e)
+	}
+	return e
+}
+
+// fill sets the fields of the struct v from the node n of its rule
+func fill(n *node.Node, v reflect.Value) error {
+	t := v.Type()
+	fs, _ := fields(t)
+	for _, f := range fs {
+		if f.blank {
+			continue
+		}
+		ns := n.Labeled(f.label(t))
+		fv := v.Field(f.Index[0])
+		switch f.shape {
+		case one:
+			if len(ns) == 0 {
+				return fmt.Errorf("structs: no match for field %s", f.label(t))
+			}
+			if err := element(ns[0], f, fv); err != nil {
+				return err
+			}
+		case optional:
+			if len(ns) > 0 {
+				fv.Set(reflect.New(f.element))
+				if err := element(ns[0], f, fv.Elem()); err != nil {
+					return err
+				}
+			}
+		case many:
+			s := reflect.MakeSlice(f.Type, len(ns), len(ns))
+			for i, c := range ns {
+				ev := s.Index(i)
+				if ev.Kind() == reflect.Ptr {
+					ev.Set(reflect.New(f.element))
+					ev = ev.Elem()
+				}
+				if err := element(c, f, ev); err != nil {
+					return err
+				}
+			}
+			fv.Set(s)
+		}
+	}
+	return nil
+}
+
+// element sets v from the node n matched by f
+func element(n *node.Node, f field, v reflect.Value) error {
+	if !f.structured() {
+		return node.Unmarshal(n, v.Addr().Interface())
+	}
+	ns := n.Labeled(f.element.Name())
+	if len(ns) == 0 {
+		return fmt.Errorf("structs: no %s matched by field %s", f.element.Name(), f.Name)
+	}
+	return fill(ns[0], v)
+}
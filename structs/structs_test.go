@@ -0,0 +1,143 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package structs
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lalloni/seared/typed"
+)
+
+type Config struct {
+	Sections []*Section `peg:""`
+}
+
+type Section struct {
+	_       struct{} `peg:"'['"`
+	Name    string   `peg:"[a-z]+"`
+	_       struct{} `peg:"']'"`
+	Entries []Entry  `peg:""`
+}
+
+type Entry struct {
+	Key   string   `peg:"[a-z]+"`
+	_     struct{} `peg:"'='"`
+	Value Value    `peg:""`
+}
+
+type Value struct {
+	Choice
+	Number *int    `peg:"'-'? [0-9]+"`
+	Text   *Quoted `peg:"'\"' (!'\"' .)* '\"'"`
+	List   *List   `peg:""`
+}
+
+type List struct {
+	_     struct{} `peg:"'('"`
+	Items []Value  `peg:"Value ','?"`
+	_     struct{} `peg:"')'"`
+}
+
+type Quoted string
+
+func (q *Quoted) UnmarshalText(text []byte) error {
+	s, err := strconv.Unquote(string(text))
+	*q = Quoted(s)
+	return err
+}
+
+func TestParser(t *testing.T) {
+	a := assert.New(t)
+	p, err := NewParser[Config](Whitespace("[ \\t\\n]*"))
+	if !a.NoError(err) {
+		return
+	}
+	c, err := p.Parse(`
+		[server]
+		port = 80
+		name = "local host"
+		[limits]
+		sizes = (1, -2, ("x"))
+	`)
+	if !a.NoError(err) {
+		return
+	}
+	if a.Len(c.Sections, 2) {
+		s := c.Sections[0]
+		a.Equal("server", s.Name)
+		if a.Len(s.Entries, 2) {
+			a.Equal("port", s.Entries[0].Key)
+			a.Equal(80, *s.Entries[0].Value.Number)
+			a.Nil(s.Entries[0].Value.Text)
+			a.Equal(Quoted("local host"), *s.Entries[1].Value.Text)
+		}
+		s = c.Sections[1]
+		if a.Len(s.Entries, 1) && a.NotNil(s.Entries[0].Value.List) {
+			items := s.Entries[0].Value.List.Items
+			if a.Len(items, 3) {
+				a.Equal(1, *items[0].Number)
+				a.Equal(-2, *items[1].Number)
+				a.Equal(Quoted("x"), *items[2].List.Items[0].Text)
+			}
+		}
+	}
+	_, err = p.Parse("[server]\nport = ")
+	a.IsType(&typed.Error{}, err)
+}
+
+type unsupported struct {
+	Channel chan int `peg:"'c'"`
+}
+
+type untagged struct {
+	Name string `peg:""`
+}
+
+type choices struct {
+	Choice
+	A string `peg:"'a'"`
+}
+
+type undefined struct {
+	Name string `peg:"Other"`
+}
+
+func TestParserErrors(t *testing.T) {
+	a := assert.New(t)
+	_, err := NewParser[unsupported]()
+	a.EqualError(err, "structs: field unsupported.Channel has unsupported type chan int")
+	_, err = NewParser[untagged]()
+	a.EqualError(err, "structs: field untagged.Name needs an expression")
+	_, err = NewParser[choices]()
+	a.EqualError(err, "structs: alternative choices.A must be a pointer")
+	_, err = NewParser[undefined]()
+	a.EqualError(err, `structs: field undefined.Name: undefined rule "Other" at position 0 (line 1, column 1)`)
+	_, err = NewParser[int]()
+	a.EqualError(err, "structs: int is not a named struct type")
+}
@@ -0,0 +1,79 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package typed
+
+import (
+	"github.com/lalloni/seared"
+)
+
+// Parser parses inputs into values of type T.
+type Parser[T any] struct {
+	parser  *seared.Parser
+	convert func(*seared.Result) (T, error)
+}
+
+// NewParser returns a parser named name for the main expression. It panics
+// like seared.NewNamedParser when the grammar has defects.
+func NewParser[T any](name string, main Expr[T]) *Parser[T] {
+	return Wrap(seared.NewNamedParser(name, main.build), func(result *seared.Result) (T, error) {
+		return value[T](result.Value()), nil
+	})
+}
+
+// Wrap returns a parser of values of type T computed by convert from the
+// successful results of parser, for parsers not built from typed expressions.
+func Wrap[T any](parser *seared.Parser, convert func(*seared.Result) (T, error)) *Parser[T] {
+	return &Parser[T]{parser: parser, convert: convert}
+}
+
+// Parser returns the untyped parser p is built on, to change its settings.
+func (p *Parser[T]) Parser() *seared.Parser {
+	return p.parser
+}
+
+// Parse returns the value of the main expression matching input or an Error
+// when it does not match.
+func (p *Parser[T]) Parse(input string) (T, error) {
+	result := p.parser.ParseString(input)
+	if !result.Success {
+		var zero T
+		return zero, &Error{Result: result}
+	}
+	return p.convert(result)
+}
+
+// Error is the error returned when parsing fails.
+type Error struct {
+	// Result is the failed result of the parse
+	Result *seared.Result
+}
+
+func (e *Error) Error() string {
+	if s := e.Result.BetterError(); s != "" {
+		return s
+	}
+	return e.Result.Error()
+}
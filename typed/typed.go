@@ -0,0 +1,244 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package typed provides parsing expressions carrying the Go type of the
+// value they produce, so parsers built with them return values of the type of
+// their main expression instead of results or parse trees to walk, for
+// example:
+//
+//	number := typed.Map(typed.Text(typed.Many1(typed.Range('0', '9'))), func(s string) int {
+//		n, _ := strconv.Atoi(s)
+//		return n
+//	})
+//	parser := typed.NewParser("Number", typed.Left(number, typed.End()))
+//	n, err := parser.Parse("42")
+//
+// Typed expressions are built on the expressions of package seared by the
+// seared.Builder of their parser when it is created.
+package typed
+
+import (
+	"github.com/lalloni/seared"
+)
+
+// Expr is a parsing expression producing a value of type T when it matches.
+type Expr[T any] struct {
+	build func(b *seared.Builder) seared.Expression
+}
+
+// Expression returns the expression built by b for e, whose only value is the
+// value of e.
+func (e Expr[T]) Expression(b *seared.Builder) seared.Expression {
+	return e.build(b)
+}
+
+// Pair holds the values produced by a sequence of two expressions.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Triple holds the values produced by a sequence of three expressions.
+type Triple[A, B, C any] struct {
+	First  A
+	Second B
+	Third  C
+}
+
+// value returns v as a T or the zero T when v is nil.
+func value[T any](v interface{}) T {
+	t, _ := v.(T)
+	return t
+}
+
+func constant(v interface{}) seared.ActionFunc {
+	return func(*seared.Result, []interface{}) interface{} {
+		return v
+	}
+}
+
+func runeValue(_ *seared.Result, values []interface{}) interface{} {
+	return []rune(values[0].(string))[0]
+}
+
+// Rune matches the rune r.
+func Rune(r rune) Expr[rune] {
+	return Expr[rune]{func(b *seared.Builder) seared.Expression {
+		return b.Action(b.Rune(r), constant(r))
+	}}
+}
+
+// Literal matches the text literal.
+func Literal(literal string) Expr[string] {
+	return Expr[string]{func(b *seared.Builder) seared.Expression {
+		return b.Action(b.Literal(literal), constant(literal))
+	}}
+}
+
+// Range matches a rune from first to last.
+func Range(first, last rune) Expr[rune] {
+	return Expr[rune]{func(b *seared.Builder) seared.Expression {
+		return b.Action(b.Range(first, last), runeValue)
+	}}
+}
+
+// AnyOf matches any of the runes of runes.
+func AnyOf(runes string) Expr[rune] {
+	return Expr[rune]{func(b *seared.Builder) seared.Expression {
+		return b.Action(b.AnyOf(runes), runeValue)
+	}}
+}
+
+// Any matches any rune.
+func Any() Expr[rune] {
+	return Expr[rune]{func(b *seared.Builder) seared.Expression {
+		return b.Action(b.Any(), runeValue)
+	}}
+}
+
+// End matches the end of input.
+func End() Expr[struct{}] {
+	return Expr[struct{}]{func(b *seared.Builder) seared.Expression {
+		return b.Action(b.End(), constant(struct{}{}))
+	}}
+}
+
+// Not matches when e does not, without consuming input.
+func Not[T any](e Expr[T]) Expr[struct{}] {
+	return Expr[struct{}]{func(b *seared.Builder) seared.Expression {
+		return b.Action(b.TestNot(e.build(b)), constant(struct{}{}))
+	}}
+}
+
+// Text produces the text matched by e.
+func Text[T any](e Expr[T]) Expr[string] {
+	return Expr[string]{func(b *seared.Builder) seared.Expression {
+		return b.Action(e.build(b), func(r *seared.Result, _ []interface{}) interface{} {
+			return r.Match()
+		})
+	}}
+}
+
+// Map produces the value computed by f from the value of e.
+func Map[A, B any](e Expr[A], f func(A) B) Expr[B] {
+	return Expr[B]{func(b *seared.Builder) seared.Expression {
+		return b.Action(e.build(b), func(_ *seared.Result, values []interface{}) interface{} {
+			return f(value[A](values[0]))
+		})
+	}}
+}
+
+// Seq2 matches a followed by b, producing both values.
+func Seq2[A, B any](a Expr[A], b Expr[B]) Expr[Pair[A, B]] {
+	return Expr[Pair[A, B]]{func(bb *seared.Builder) seared.Expression {
+		return bb.Action(bb.Sequence(a.build(bb), b.build(bb)), func(_ *seared.Result, values []interface{}) interface{} {
+			return Pair[A, B]{value[A](values[0]), value[B](values[1])}
+		})
+	}}
+}
+
+// Seq3 matches a, b and c in sequence, producing their values.
+func Seq3[A, B, C any](a Expr[A], b Expr[B], c Expr[C]) Expr[Triple[A, B, C]] {
+	return Expr[Triple[A, B, C]]{func(bb *seared.Builder) seared.Expression {
+		return bb.Action(bb.Sequence(a.build(bb), b.build(bb), c.build(bb)), func(_ *seared.Result, values []interface{}) interface{} {
+			return Triple[A, B, C]{value[A](values[0]), value[B](values[1]), value[C](values[2])}
+		})
+	}}
+}
+
+// Left matches a followed by b, producing the value of a.
+func Left[A, B any](a Expr[A], b Expr[B]) Expr[A] {
+	return Map(Seq2(a, b), func(p Pair[A, B]) A { return p.First })
+}
+
+// Right matches a followed by b, producing the value of b.
+func Right[A, B any](a Expr[A], b Expr[B]) Expr[B] {
+	return Map(Seq2(a, b), func(p Pair[A, B]) B { return p.Second })
+}
+
+// Choice matches the first of alternatives matching, producing its value.
+func Choice[T any](alternatives ...Expr[T]) Expr[T] {
+	return Expr[T]{func(b *seared.Builder) seared.Expression {
+		es := make([]seared.Expression, len(alternatives))
+		for i, a := range alternatives {
+			es[i] = a.build(b)
+		}
+		return b.Choice(es...)
+	}}
+}
+
+// Optional matches e if possible, producing its value or otherwise when it
+// does not match.
+func Optional[T any](e Expr[T], otherwise T) Expr[T] {
+	return Expr[T]{func(b *seared.Builder) seared.Expression {
+		return b.Action(b.Optional(e.build(b)), func(_ *seared.Result, values []interface{}) interface{} {
+			if len(values) == 0 {
+				return otherwise
+			}
+			return values[0]
+		})
+	}}
+}
+
+// Many matches e zero or more times, producing its values.
+func Many[T any](e Expr[T]) Expr[[]T] {
+	return Expr[[]T]{func(b *seared.Builder) seared.Expression {
+		return b.Action(b.ZeroOrMore(e.build(b)), values[T])
+	}}
+}
+
+// Many1 matches e one or more times, producing its values.
+func Many1[T any](e Expr[T]) Expr[[]T] {
+	return Expr[[]T]{func(b *seared.Builder) seared.Expression {
+		return b.Action(b.OneOrMore(e.build(b)), values[T])
+	}}
+}
+
+func values[T any](_ *seared.Result, values []interface{}) interface{} {
+	ts := make([]T, len(values))
+	for i, v := range values {
+		ts[i] = value[T](v)
+	}
+	return ts
+}
+
+// Rule returns a rule named name matching the expression returned by body,
+// which is called once per parser. Recursive expressions must be defined by
+// rules, for example:
+//
+//	func List() typed.Expr[[]string] {
+//		return typed.Rule("List", func() typed.Expr[[]string] {
+//			return typed.Right(typed.Rune('('), typed.Left(typed.Many(Item()), typed.Rune(')')))
+//		})
+//	}
+//
+// where Item may refer to List.
+func Rule[T any](name string, body func() Expr[T]) Expr[T] {
+	return Expr[T]{func(b *seared.Builder) seared.Expression {
+		return b.NamedRule(name, func() seared.Expression {
+			return body().build(b)
+		})
+	}}
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2017, Pablo Lalloni <plalloni@gmail.com>.
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package typed
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func number() Expr[int] {
+	return Rule("Number", func() Expr[int] {
+		return Map(Text(Many1(Range('0', '9'))), func(s string) int {
+			n, _ := strconv.Atoi(s)
+			return n
+		})
+	})
+}
+
+func factor() Expr[int] {
+	return Rule("Factor", func() Expr[int] {
+		return Choice(number(), Right(Rune('('), Left(sum(), Rune(')'))))
+	})
+}
+
+func operation(operand Expr[int], operators string) Expr[int] {
+	return Map(Seq2(operand, Many(Seq2(AnyOf(operators), operand))), func(p Pair[int, []Pair[rune, int]]) int {
+		n := p.First
+		for _, o := range p.Second {
+			switch o.First {
+			case '+':
+				n += o.Second
+			case '-':
+				n -= o.Second
+			case '*':
+				n *= o.Second
+			case '/':
+				n /= o.Second
+			}
+		}
+		return n
+	})
+}
+
+func sum() Expr[int] {
+	return Rule("Sum", func() Expr[int] {
+		return operation(Rule("Term", func() Expr[int] { return operation(factor(), "*/") }), "+-")
+	})
+}
+
+func TestParser(t *testing.T) {
+	a := assert.New(t)
+	p := NewParser("Calculator", Left(sum(), End()))
+	for input, expected := range map[string]int{"1": 1, "2+1*3": 5, "10*(2+1)": 30, "10-2-3": 5, "2*(3+4)/7-1": 1} {
+		n, err := p.Parse(input)
+		a.NoError(err, input)
+		a.Equal(expected, n, input)
+	}
+	_, err := p.Parse("2+a")
+	if a.Error(err) {
+		a.IsType(&Error{}, err)
+		a.Contains(err.Error(), "at position 2")
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	a := assert.New(t)
+	word := Text(Many1(Seq2(Not(Rune(' ')), Any())))
+	p := NewParser("Words", Seq3(Literal("say"), Many(Right(Rune(' '), word)), Optional(Right(Rune('!'), Literal("!")), "?")))
+	v, err := p.Parse("say hello world!!")
+	a.NoError(err)
+	a.Equal(Triple[string, []string, string]{"say", []string{"hello", "world!!"}, "?"}, v)
+	v, err = p.Parse("say")
+	a.NoError(err)
+	a.Equal([]string{}, v.Second)
+	_, err = p.Parse("hey")
+	a.Error(err)
+}